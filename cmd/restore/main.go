@@ -0,0 +1,57 @@
+// restore. restore online backup (lihat pkg/backup) ke directory database baru.
+// dengan -archive, log archive setelah backup direplay sampai -lsn / -time (point-in-time recovery), atau sampai akhir archive.
+//
+//	restore -backup <backup dir> -target <database dir> [-archive <archive dir> [-lsn <lsn>] [-time <RFC3339 time>]]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/backup"
+)
+
+func main() {
+	backupDir := flag.String("backup", "", "backup directory")
+	targetDir := flag.String("target", "", "database directory to restore into (must be empty)")
+	archiveDir := flag.String("archive", "", "log archive directory to replay after the backup")
+	targetLSN := flag.Int("lsn", 0, "replay archived log up to this lsn (requires -archive)")
+	targetTime := flag.String("time", "", "replay archived log up to this RFC3339 time (requires -archive)")
+	flag.Parse()
+	if *backupDir == "" || *targetDir == "" || *archiveDir == "" && (*targetLSN != 0 || *targetTime != "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *archiveDir != "" {
+		restoreToPoint(*backupDir, *archiveDir, *targetDir, *targetLSN, *targetTime)
+		return
+	}
+	info, err := backup.Restore(*backupDir, *targetDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %d files from backup of %s (lsn %d-%d) into %s\n", len(info.Files), info.CreatedAt.Format("2006-01-02 15:04:05"), info.StartLSN, info.EndLSN, *targetDir)
+}
+
+// restoreToPoint. restore backup backupDir & replay log archive archiveDir sampai lsn / waktu targetTime.
+func restoreToPoint(backupDir, archiveDir, targetDir string, lsn int, targetTime string) {
+	target := backup.RecoveryTarget{LSN: lsn}
+	if targetTime != "" {
+		var err error
+		target.Time, err = time.Parse(time.RFC3339Nano, targetTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -time: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	lsn, err := backup.RestoreToPoint(backupDir, archiveDir, targetDir, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored backup %s and archived log up to lsn %d into %s\n", backupDir, lsn, targetDir)
+}
@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+log archive. log database dicopy berkala (ArchiveLog) ke directory archive, jadi database bisa direstore ke LSN / waktu tertentu
+setelah backup (RestoreToPoint): restore backup, lalu replay page image di log archive sampai titik itu.
+database harus jalan dengan page logging (log.WithPageLogging) biar isi setiap block yang diwrite ada di log.
+
+archive berisi file log / segment log dengan nama & posisi block yang sama dengan di database (lihat LogManager.ArchiveTo), jadi LSN record di archive
+sama dengan di log database. segment yang dipindah ke archive setelah checkpoint ikut dipakai: DiskManager archive yang dipasang dengan
+log.WithSegmentArchive sudah hold lock directory archive, jadi arsipkan log ke DiskManager yang sama dengan ArchiveLogTo (bukan ArchiveLog).
+archive.json mencatat LSN record terakhir yang sudah diarsip, ditulis setelah block log di fsync.
+*/
+
+const (
+	// ArchiveInfoFile. file info archive di directory archive.
+	ArchiveInfoFile = "archive.json"
+)
+
+var (
+	ErrArchiveGap         = errors.New("archive does not continue from backup")
+	ErrTargetBeforeBackup = errors.New("recovery target is before backup")
+	ErrTargetNotArchived  = errors.New("recovery target is not archived")
+)
+
+// ArchiveInfo. info log archive.
+type ArchiveInfo struct {
+	LogFile   string    `json:"log_file"` // nama log file database
+	EndLSN    int       `json:"end_lsn"`  // LSN record terakhir yang sudah diarsip
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecoveryTarget. titik restore RestoreToPoint. field yang zero tidak membatasi replay.
+type RecoveryTarget struct {
+	LSN  int       // replay log record sampai LSN ini
+	Time time.Time // replay log record sampai sebelum commit pertama dengan waktu commit setelah Time
+}
+
+/*
+ArchiveLog. copy log database dm yang belum diarsip (record dengan LSN > EndLSN archive) dari lm ke directory archive archiveDir (dibuat kalau belum ada).
+opts dipakai buat buka DiskManager archive (mis. WithVFS).
+*/
+func ArchiveLog(dm *storage.DiskManager, lm *log.LogManager, archiveDir string, opts ...storage.DiskManagerOption) (*ArchiveInfo, error) {
+	dst, err := storage.NewDiskManager(archiveDir, dm.BlockSize(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	info, err := archiveLog(lm, dst)
+	if err != nil {
+		return nil, errors.Join(err, dst.Close())
+	}
+	return info, dst.Close()
+}
+
+/*
+ArchiveLogTo. sama dengan ArchiveLog, tapi ke DiskManager archive yang sudah dibuka (mis. DiskManager yang dipasang dengan log.WithSegmentArchive).
+archive tidak diclose.
+*/
+func ArchiveLogTo(lm *log.LogManager, archive *storage.DiskManager) (*ArchiveInfo, error) {
+	return archiveLog(lm, archive)
+}
+
+func archiveLog(lm *log.LogManager, dst *storage.DiskManager) (*ArchiveInfo, error) {
+	info := &ArchiveInfo{LogFile: lm.LogFile()}
+	// archive.json belum ada kalau log belum pernah diarsip, walaupun directory archive sudah berisi segment dari Checkpoint
+	_, err := dst.GetVFS().Stat(filepath.Join(dst.GetDBDir(), ArchiveInfoFile))
+	if err == nil || !dst.IsNew() && !errors.Is(err, fs.ErrNotExist) {
+		info, err = ReadArchiveInfo(dst.GetVFS(), dst.GetDBDir())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if info.LogFile != lm.LogFile() {
+		return nil, fmt.Errorf("archive %s has log file %s, not %s", dst.GetDBDir(), info.LogFile, lm.LogFile())
+	}
+	if lm.LatestLSN() < info.EndLSN {
+		return nil, fmt.Errorf("%w: latest lsn %d, archived up to lsn %d", ErrLSNRegressed, lm.LatestLSN(), info.EndLSN)
+	}
+	endLSN, err := lm.ArchiveTo(dst, info.EndLSN)
+	if err != nil {
+		return nil, err
+	}
+	info.EndLSN = endLSN
+	info.UpdatedAt = time.Now()
+	return info, writeJSON(dst, ArchiveInfoFile, info)
+}
+
+// ReadArchiveInfo. read info archive di directory archiveDir.
+func ReadArchiveInfo(fs storage.VFS, archiveDir string) (*ArchiveInfo, error) {
+	info := &ArchiveInfo{}
+	err := readJSON(fs, filepath.Join(archiveDir, ArchiveInfoFile), info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive info %s: %w", archiveDir, err)
+	}
+	return info, nil
+}
+
+/*
+RestoreToPoint. restore backup backupDir ke directory database baru targetDir, lalu replay log archive archiveDir setelah backup sampai target.
+return LSN log record terakhir yang direplay. page image yang terpotong target (sebagian record nya setelah target) tidak ditulis.
+*/
+func RestoreToPoint(backupDir, archiveDir, targetDir string, target RecoveryTarget, opts ...storage.DiskManagerOption) (int, error) {
+	src, err := openBackup(backupDir, opts)
+	if err != nil {
+		return 0, err
+	}
+	info, err := ReadInfo(src.GetVFS(), backupDir)
+	err = errors.Join(err, src.Close())
+	if err != nil {
+		return 0, err
+	}
+	archive, err := openBackup(archiveDir, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+	ainfo, err := ReadArchiveInfo(archive.GetVFS(), archiveDir)
+	if err != nil {
+		return 0, err
+	}
+	if target.LSN > 0 && target.LSN < info.EndLSN || !target.Time.IsZero() && target.Time.Before(info.CompletedAt) {
+		return 0, fmt.Errorf("%w: backup %s ends at lsn %d (%s)", ErrTargetBeforeBackup, backupDir, info.EndLSN, info.CompletedAt.Format(time.RFC3339))
+	}
+	if target.LSN > ainfo.EndLSN {
+		return 0, fmt.Errorf("%w: lsn %d, archived up to lsn %d", ErrTargetNotArchived, target.LSN, ainfo.EndLSN)
+	}
+	if ainfo.EndLSN < info.EndLSN {
+		return 0, fmt.Errorf("%w: backup %s ends at lsn %d, archived up to lsn %d", ErrArchiveGap, backupDir, info.EndLSN, ainfo.EndLSN)
+	}
+	records, err := log.ReadRecords(archive, ainfo.LogFile, info.EndLSN)
+	if errors.Is(err, log.ErrLSNOutOfRange) {
+		return 0, fmt.Errorf("%w: backup %s ends at lsn %d: %w", ErrArchiveGap, backupDir, info.EndLSN, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// record yang dicopy setelah archive.json terakhir ditulis belum dianggap diarsip
+	for len(records) > 0 && records[len(records)-1].LSN > ainfo.EndLSN {
+		records = records[:len(records)-1]
+	}
+
+	_, err = Restore(backupDir, targetDir, opts...)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := storage.NewDiskManager(targetDir, info.BlockSize, opts...)
+	if err != nil {
+		return 0, err
+	}
+	data := make([][]byte, len(records))
+	for i, record := range records {
+		data[i] = record.Data
+	}
+	n, err := replay(dst, data, func(i int, record []byte) bool {
+		if target.LSN > 0 && records[i].LSN > target.LSN {
+			return true
+		}
+		if target.Time.IsZero() || log.GetRecordType(record) != log.CommitRecord {
+			return false
+		}
+		commit, err := log.DecodeCommit(record)
+		return err == nil && commit.Time.After(target.Time)
+	})
+	if err == nil {
+		err = dst.Sync()
+	}
+	lsn := info.EndLSN
+	if n > 0 {
+		lsn = records[n-1].LSN
+	}
+	return lsn, errors.Join(err, dst.Close())
+}
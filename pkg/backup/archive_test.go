@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreToPoint(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_pitr", 4096, storage.WithVFS(fs), storage.WithFileBlockSize(8192, "new.db"))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log", log.WithPageLogging(true))
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		writeGen(t, dm, "test.db", i, 1)
+	}
+	_, err = lm.Commit(1)
+	assert.NoError(t, err)
+	info, err := Backup(dm, lm, "lintangdb_pitr_backup", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.NotNil(t, dm.PageLogger(), "page logging tetap aktif setelah backup")
+	_, err = ArchiveLog(dm, lm, "lintangdb_pitr_archive", storage.WithVFS(fs))
+	assert.NoError(t, err)
+
+	writeGen(t, dm, "test.db", 1, 2)
+	lsn2, err := lm.Commit(2)
+	assert.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	afterCommit2 := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	writeGen(t, dm, "test.db", 2, 3)
+	lsn3, err := lm.Commit(3)
+	assert.NoError(t, err)
+	_, err = ArchiveLog(dm, lm, "lintangdb_pitr_archive", storage.WithVFS(fs))
+	assert.NoError(t, err)
+
+	assert.NoError(t, dm.CreateFile("new.db", 8192))
+	writeGen(t, dm, "new.db", 0, 4)
+	_, err = dm.Append("test.db")
+	assert.NoError(t, err)
+	writeGen(t, dm, "test.db", 5, 4)
+	lsn4, err := lm.Commit(4)
+	assert.NoError(t, err)
+	ainfo, err := ArchiveLog(dm, lm, "lintangdb_pitr_archive", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, "lintangdb.log", ainfo.LogFile)
+	assert.Equal(t, lsn4, ainfo.EndLSN)
+
+	readAll := func(dir string) (gens []int, newGen int) {
+		rdm, err := storage.NewDiskManager(dir, 0, storage.WithVFS(fs))
+		assert.NoError(t, err)
+		defer rdm.Close()
+		n, err := rdm.BlockLength("test.db")
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			gens = append(gens, readGen(t, rdm, "test.db", i))
+		}
+		if n, _ := rdm.BlockLength("new.db"); n > 0 {
+			assert.Equal(t, 8192, rdm.FileBlockSize("new.db"))
+			newGen = readGen(t, rdm, "new.db", 0)
+		}
+		return gens, newGen
+	}
+
+	lsn, err := RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_lsn", RecoveryTarget{LSN: lsn2}, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, lsn2, lsn)
+	gens, newGen := readAll("lintangdb_pitr_lsn")
+	assert.Equal(t, []int{1, 2, 1, 1, 1}, gens)
+	assert.Equal(t, 0, newGen)
+
+	// replay berhenti tepat sebelum commit pertama setelah target waktu
+	lsn, err = RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_time", RecoveryTarget{Time: afterCommit2}, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Greater(t, lsn, lsn2)
+	assert.Less(t, lsn, lsn3)
+
+	lsn, err = RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_all", RecoveryTarget{}, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, lsn4, lsn)
+	gens, newGen = readAll("lintangdb_pitr_all")
+	assert.Equal(t, []int{1, 2, 3, 1, 1, 4}, gens)
+	assert.Equal(t, 4, newGen)
+
+	_, err = RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_err", RecoveryTarget{LSN: info.EndLSN - 1}, storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrTargetBeforeBackup)
+	_, err = RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_err", RecoveryTarget{Time: info.CreatedAt}, storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrTargetBeforeBackup)
+	_, err = RestoreToPoint("lintangdb_pitr_backup", "lintangdb_pitr_archive", "lintangdb_pitr_err", RecoveryTarget{LSN: lsn4 + 1}, storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrTargetNotArchived)
+}
+
+func TestArchiveLogWithSegmentArchive(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_segarchive", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	archive, err := storage.NewDiskManager("lintangdb_segarchive_archive", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log", log.WithPageLogging(true), log.WithSegmentSize(2), log.WithSegmentArchive(archive))
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		writeGen(t, dm, "test.db", i, 1)
+	}
+	_, err = lm.Commit(1)
+	assert.NoError(t, err)
+	_, err = Backup(dm, lm, "lintangdb_segarchive_backup", storage.WithVFS(fs))
+	assert.NoError(t, err)
+
+	// directory archive sudah di lock DiskManager archive segment
+	_, err = ArchiveLog(dm, lm, "lintangdb_segarchive_archive", storage.WithVFS(fs))
+	assert.Error(t, err)
+	_, err = ArchiveLogTo(lm, archive)
+	assert.NoError(t, err)
+
+	// segment berisi record yang belum diarsip ArchiveLogTo dipindah ke archive oleh Checkpoint
+	for i := 0; i < 4; i++ {
+		writeGen(t, dm, "test.db", i, 2)
+	}
+	_, err = lm.Commit(2)
+	assert.NoError(t, err)
+	_, err = lm.Checkpoint()
+	assert.NoError(t, err)
+	writeGen(t, dm, "test.db", 1, 3)
+	lsn3, err := lm.Commit(3)
+	assert.NoError(t, err)
+	ainfo, err := ArchiveLogTo(lm, archive)
+	assert.NoError(t, err)
+	assert.Equal(t, lsn3, ainfo.EndLSN)
+	assert.NoError(t, archive.Close())
+
+	lsn, err := RestoreToPoint("lintangdb_segarchive_backup", "lintangdb_segarchive_archive", "lintangdb_segarchive_restore", RecoveryTarget{}, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, lsn3, lsn)
+	rdm, err := storage.NewDiskManager("lintangdb_segarchive_restore", 0, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	defer rdm.Close()
+	var gens []int
+	for i := 0; i < 4; i++ {
+		gens = append(gens, readGen(t, rdm, "test.db", i))
+	}
+	assert.Equal(t, []int{2, 3, 2, 2}, gens)
+}
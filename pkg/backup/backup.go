@@ -0,0 +1,307 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+online hot backup. semua file block database dicopy per block (tiap block diread sambil hold latch block, jadi page consistent) selagi database tetap jalan.
+selama copy, DiskManager log page image setiap block yang diwrite (DiskManager.SetPageLogger). block yang diwrite setelah dicopy isinya ada di log tail.
+setelah copy selesai, log tail (log record dari LSN awal backup sampai akhir) disimpan di backup. Restore copy backup & replay page image di log tail,
+jadi database hasil restore consistent dengan database pas backup selesai.
+
+incremental backup cuma copy block yang block LSN nya >= CutoffLSN backup sebelumnya (base), yaitu block yang diwrite setelah backup base selesai.
+backup full & incremental setelahnya membentuk chain: Info tiap backup mencatat directory semua backup di chain nya, dari backup full sampai backup itu sendiri.
+
+backup disimpan sebagai directory database biasa (semua file di tablespace default, tanpa compression/encryption kecuali diset lewat option)
+ditambah file backup.log (log tail) & backup.json (Info).
+*/
+
+const (
+	// InfoFile. file info backup di directory backup.
+	InfoFile = "backup.json"
+	// LogFile. log tail backup di directory backup.
+	LogFile = "backup.log"
+)
+
+// Type. jenis backup.
+type Type string
+
+const (
+	Full        Type = "full"
+	Incremental Type = "incremental"
+)
+
+var (
+	ErrBackupExists   = errors.New("backup directory is not empty")
+	ErrTargetNotEmpty = errors.New("restore target is not empty")
+	ErrLSNRegressed   = errors.New("log lsn is behind base backup")
+)
+
+// Info. info satu backup.
+type Info struct {
+	Type        Type       `json:"type"`
+	Chain       []string   `json:"chain"`      // directory backup di chain, dari backup full sampai backup ini
+	SinceLSN    int        `json:"since_lsn"`  // incremental: block dengan block LSN >= SinceLSN yang dicopy (CutoffLSN backup base)
+	StartLSN    int        `json:"start_lsn"`  // LSN terakhir sebelum copy dimulai
+	CutoffLSN   int        `json:"cutoff_lsn"` // LSN record backup checkpoint. block yang diwrite setelah checkpoint punya block LSN >= CutoffLSN
+	EndLSN      int        `json:"end_lsn"`    // LSN terakhir log tail
+	BlockSize   int        `json:"block_size"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt time.Time  `json:"completed_at"` // waktu backup checkpoint. commit yang ada di backup punya waktu commit <= CompletedAt
+	Files       []FileInfo `json:"files"`
+}
+
+// FileInfo. file block di backup.
+type FileInfo struct {
+	Name      string `json:"name"`
+	BlockSize int    `json:"block_size"`
+	Blocks    int    `json:"blocks"`            // jumlah block file pas dicopy
+	Changed   []int  `json:"changed,omitempty"` // incremental: block yang dicopy
+}
+
+/*
+Backup. online backup database dm ke directory dstDir (harus kosong / belum ada). writer tetap bisa write selama backup.
+lm log manager database dm, log tail dibaca dari lm. opts dipakai buat buka DiskManager backup (mis. WithVFS).
+hanya satu backup yang boleh jalan dalam satu waktu per DiskManager. kalau page logger lain sudah dipasang di dm (mis. archive mode), page logger nya harus lm.
+*/
+func Backup(dm *storage.DiskManager, lm *log.LogManager, dstDir string, opts ...storage.DiskManagerOption) (*Info, error) {
+	return runBackup(dm, lm, dstDir, &Info{Type: Full, Chain: []string{dstDir}}, opts)
+}
+
+/*
+IncrementalBackup. online backup block database dm yang berubah sejak backup baseDir (full / incremental) ke directory dstDir.
+block LSN harus dicatat sejak sebelum backup baseDir (lm dibuat dengan log.NewLogManager di atas dm) & LSN log tidak boleh mundur.
+*/
+func IncrementalBackup(dm *storage.DiskManager, lm *log.LogManager, baseDir, dstDir string, opts ...storage.DiskManagerOption) (*Info, error) {
+	src, err := openBackup(baseDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	base, err := ReadInfo(src.GetVFS(), baseDir)
+	err = errors.Join(err, src.Close())
+	if err != nil {
+		return nil, err
+	}
+	if lm.LatestLSN() < base.CutoffLSN {
+		return nil, fmt.Errorf("%w: latest lsn %d, base backup %s cutoff lsn %d", ErrLSNRegressed, lm.LatestLSN(), baseDir, base.CutoffLSN)
+	}
+	info := &Info{Type: Incremental, Chain: append(slices.Clone(base.Chain), dstDir), SinceLSN: base.CutoffLSN}
+	return runBackup(dm, lm, dstDir, info, opts)
+}
+
+func runBackup(dm *storage.DiskManager, lm *log.LogManager, dstDir string, info *Info, opts []storage.DiskManagerOption) (*Info, error) {
+	dst, err := storage.NewDiskManager(dstDir, dm.BlockSize(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	err = backup(dm, lm, dst, info)
+	if err != nil {
+		return nil, errors.Join(err, dst.Close())
+	}
+	return info, dst.Close()
+}
+
+func backup(dm *storage.DiskManager, lm *log.LogManager, dst *storage.DiskManager, info *Info) error {
+	if !dst.IsNew() {
+		return fmt.Errorf("%w: %s", ErrBackupExists, dst.GetDBDir())
+	}
+	// LSN awal dicatat sebelum page logging aktif: page image yang di log setelah ini pasti punya LSN > StartLSN
+	info.StartLSN = lm.LatestLSN()
+	info.BlockSize = dm.BlockSize()
+	info.CreatedAt = time.Now()
+	// page logger yang sudah dipasang (mis. archive mode, log.WithPageLogging) dipasang lagi setelah backup
+	prevLogger := dm.PageLogger()
+	dm.SetPageLogger(lm)
+	defer dm.SetPageLogger(prevLogger)
+
+	copied := make(map[string]bool)
+	for _, name := range dm.Files() {
+		if lm.IsLogFile(name) {
+			continue
+		}
+		var (
+			file FileInfo
+			err  error
+		)
+		if info.Type == Incremental {
+			file, err = copyChangedBlocks(dm, dst, name, info.SinceLSN)
+		} else {
+			file, err = copyFile(dm, dst, name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+		info.Files = append(info.Files, file)
+		copied[name] = true
+	}
+
+	// block yang diwrite sebelum checkpoint sudah dicopy / ada di log tail. block yang diwrite setelahnya punya block LSN >= CutoffLSN
+	cutoff, err := lm.Append(log.NewBackupCheckpoint())
+	if err != nil {
+		return err
+	}
+	info.CutoffLSN = cutoff
+	info.CompletedAt = time.Now()
+	dm.SetPageLogger(prevLogger)
+	records, endLSN, err := lm.RecordsSince(info.StartLSN)
+	if err != nil {
+		return err
+	}
+	info.EndLSN = endLSN
+
+	// file yang dibuat selama copy (isinya ada di log tail)
+	for _, name := range dm.Files() {
+		if copied[name] || lm.IsLogFile(name) {
+			continue
+		}
+		err = dst.CreateFile(name, dm.FileBlockSize(name))
+		if err != nil {
+			return err
+		}
+		info.Files = append(info.Files, FileInfo{Name: name, BlockSize: dm.FileBlockSize(name)})
+	}
+
+	err = appendLog(dst, LogFile, dm.FileBlockSize(lm.LogFile()), records)
+	if err != nil {
+		return err
+	}
+	return writeInfo(dst, info)
+}
+
+// appendLog. append log record ke log file logFile (dibuat dengan block size blockSize kalau belum ada) di dm & fsync.
+func appendLog(dm *storage.DiskManager, logFile string, blockSize int, records []log.Record) error {
+	err := dm.CreateFile(logFile, blockSize)
+	if err != nil {
+		return err
+	}
+	lm, err := log.NewLogManager(dm, logFile)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		_, err = lm.Append(record.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return lm.Flush2()
+}
+
+// copyFile. copy semua block file name dari src ke dst (file dibuat di dst dengan block size yang sama).
+func copyFile(src, dst *storage.DiskManager, name string) (FileInfo, error) {
+	file, err := copyBlocks(src, dst, name, func(blockID storage.BlockID) (bool, error) { return true, nil })
+	file.Changed = nil
+	return file, err
+}
+
+// copyChangedBlocks. copy block file name dari src ke dst yang block LSN nya >= sinceLSN. block lain di dst dibiarkan kosong.
+func copyChangedBlocks(src, dst *storage.DiskManager, name string, sinceLSN int) (FileInfo, error) {
+	file, err := copyBlocks(src, dst, name, func(blockID storage.BlockID) (bool, error) {
+		lsn, err := src.ReadBlockLSN(blockID)
+		return lsn >= sinceLSN, err
+	})
+	if file.Changed == nil {
+		file.Changed = []int{}
+	}
+	return file, err
+}
+
+// copyBlocks. copy block file name dari src ke dst yang lolos filter. file dibuat di dst dengan block size yang sama.
+func copyBlocks(src, dst *storage.DiskManager, name string, filter func(blockID storage.BlockID) (bool, error)) (FileInfo, error) {
+	blockSize := src.FileBlockSize(name)
+	file := FileInfo{Name: name, BlockSize: blockSize}
+	err := dst.CreateFile(name, blockSize)
+	if err != nil {
+		return file, err
+	}
+	numBlocks, err := src.BlockLength(name)
+	if err != nil {
+		return file, err
+	}
+	page := storage.NewPage(blockSize)
+	for ; file.Blocks < numBlocks; file.Blocks++ {
+		blockID := storage.NewBlockID(name, file.Blocks)
+		ok, err := filter(blockID)
+		if err != nil {
+			return file, err
+		}
+		if !ok {
+			continue
+		}
+		err = src.Read(blockID, page)
+		if err == io.EOF {
+			break // file di truncate selama copy
+		}
+		if err != nil {
+			return file, err
+		}
+		err = dst.Write(blockID, page)
+		if err != nil {
+			return file, err
+		}
+		file.Changed = append(file.Changed, file.Blocks)
+	}
+	return file, dst.SyncFile(name)
+}
+
+// writeInfo. tulis info backup ke directory backup dm.
+func writeInfo(dm *storage.DiskManager, info *Info) error {
+	return writeJSON(dm, InfoFile, info)
+}
+
+// writeJSON. tulis v sebagai json ke file filename di directory database dm.
+func writeJSON(dm *storage.DiskManager, filename string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := dm.GetVFS().OpenFile(filepath.Join(dm.GetDBDir(), filename), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(b, 0)
+	if err == nil {
+		err = f.Sync()
+	}
+	return errors.Join(err, f.Close())
+}
+
+// ReadInfo. read info backup di directory backupDir.
+func ReadInfo(fs storage.VFS, backupDir string) (*Info, error) {
+	info := &Info{}
+	err := readJSON(fs, filepath.Join(backupDir, InfoFile), info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup info %s: %w", backupDir, err)
+	}
+	return info, nil
+}
+
+// readJSON. read file json path ke v.
+func readJSON(fs storage.VFS, path string, v any) error {
+	f, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, size)
+	_, err = f.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
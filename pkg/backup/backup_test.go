@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// hookFS. VFS yang panggil onRead sekali pas block tertentu diread (buat simulasi write selama backup).
+type hookFS struct {
+	storage.VFS
+	mu     sync.Mutex
+	onRead func(name string, off int64) bool // return true kalau hook sudah selesai
+}
+
+type hookFile struct {
+	storage.File
+	fs   *hookFS
+	name string
+}
+
+func (h *hookFS) OpenFile(name string, flag int, perm os.FileMode) (storage.File, error) {
+	f, err := h.VFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &hookFile{File: f, fs: h, name: name}, nil
+}
+
+func (f *hookFile) ReadAt(b []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	onRead := f.fs.onRead
+	f.fs.mu.Unlock()
+	if onRead != nil && onRead(f.name, off) {
+		f.fs.mu.Lock()
+		f.fs.onRead = nil
+		f.fs.mu.Unlock()
+	}
+	return f.File.ReadAt(b, off)
+}
+
+// writeGen. write generasi gen ke block blockNum file name. gen ditulis di awal & akhir page.
+func writeGen(t *testing.T, dm *storage.DiskManager, name string, blockNum, gen int) {
+	page := storage.NewPage(dm.FileBlockSize(name))
+	page.PutInt(0, gen)
+	page.PutInt(len(page.Contents())-4, gen)
+	assert.NoError(t, dm.WriteBlocks([]storage.BlockWrite{{BlockID: storage.NewBlockID(name, blockNum), Page: page}}))
+}
+
+// readGen. read generasi block blockNum file name & cek page tidak torn.
+func readGen(t *testing.T, dm *storage.DiskManager, name string, blockNum int) int {
+	page := storage.NewPage(dm.FileBlockSize(name))
+	assert.NoError(t, dm.Read(storage.NewBlockID(name, blockNum), page))
+	assert.Equal(t, page.GetInt(0), page.GetInt(len(page.Contents())-4), "torn page %s block %d", name, blockNum)
+	return page.GetInt(0)
+}
+
+func TestBackupRestore(t *testing.T) {
+	fs := storage.NewMemFS()
+	hfs := &hookFS{VFS: fs}
+	dm, err := storage.NewDiskManager("lintangdb_backup", 4096, storage.WithVFS(hfs), storage.WithFileBlockSize(8192, "index.db", "new.db"))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		writeGen(t, dm, "test.db", i, 1)
+	}
+	for i := 0; i < 3; i++ {
+		writeGen(t, dm, "index.db", i, 1)
+	}
+
+	// write selama backup: block yang sudah dicopy, yang belum dicopy, block baru & file baru
+	hfs.onRead = func(name string, off int64) bool {
+		if !strings.HasSuffix(name, "lintangdb_backup/test.db") || off != 5*4096 {
+			return false
+		}
+		writeGen(t, dm, "test.db", 2, 2)
+		writeGen(t, dm, "test.db", 8, 2)
+		_, err := dm.Append("test.db")
+		assert.NoError(t, err)
+		writeGen(t, dm, "test.db", 10, 2)
+		writeGen(t, dm, "index.db", 1, 2)
+		assert.NoError(t, dm.CreateFile("new.db", 8192))
+		writeGen(t, dm, "new.db", 0, 2)
+		return true
+	}
+
+	info, err := Backup(dm, lm, "lintangdb_backup_dst", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Nil(t, hfs.onRead)
+	assert.Greater(t, info.EndLSN, info.StartLSN)
+	writeGen(t, dm, "test.db", 3, 3) // write setelah backup selesai tidak masuk backup
+
+	_, err = Backup(dm, lm, "lintangdb_backup_dst", storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrBackupExists)
+
+	restored, err := Restore("lintangdb_backup_dst", "lintangdb_restored", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, info.EndLSN, restored.EndLSN)
+
+	rdm, err := storage.NewDiskManager("lintangdb_restored", 0, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	n, err := rdm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+	for i := 0; i < n; i++ {
+		want := 1
+		if i == 2 || i == 8 || i == 10 {
+			want = 2
+		}
+		assert.Equal(t, want, readGen(t, rdm, "test.db", i), "test.db block %d", i)
+	}
+	for i := 0; i < 3; i++ {
+		want := 1
+		if i == 1 {
+			want = 2
+		}
+		assert.Equal(t, want, readGen(t, rdm, "index.db", i), "index.db block %d", i)
+	}
+	assert.Equal(t, 8192, rdm.FileBlockSize("new.db"))
+	assert.Equal(t, 2, readGen(t, rdm, "new.db", 0))
+	assert.NoError(t, rdm.Close())
+
+	_, err = Restore("lintangdb_backup_dst", "lintangdb_restored", storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrTargetNotEmpty)
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_incr", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		writeGen(t, dm, "test.db", i, 1)
+	}
+	full, err := Backup(dm, lm, "lintangdb_incr_full", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, Full, full.Type)
+
+	writeGen(t, dm, "test.db", 3, 2)
+	writeGen(t, dm, "test.db", 7, 2)
+	_, err = dm.Append("test.db")
+	assert.NoError(t, err)
+	writeGen(t, dm, "test.db", 10, 2)
+	assert.NoError(t, dm.CreateFile("new.db", 8192))
+	writeGen(t, dm, "new.db", 0, 2)
+
+	incr1, err := IncrementalBackup(dm, lm, "lintangdb_incr_full", "lintangdb_incr_1", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, Incremental, incr1.Type)
+	assert.Equal(t, full.CutoffLSN, incr1.SinceLSN)
+	assert.Equal(t, []string{"lintangdb_incr_full", "lintangdb_incr_1"}, incr1.Chain)
+	for _, file := range incr1.Files {
+		switch file.Name {
+		case "test.db":
+			assert.Equal(t, []int{3, 7, 10}, file.Changed)
+			assert.Equal(t, 11, file.Blocks)
+		case "new.db":
+			assert.Equal(t, []int{0}, file.Changed)
+		}
+	}
+
+	writeGen(t, dm, "test.db", 5, 3)
+	incr2, err := IncrementalBackup(dm, lm, "lintangdb_incr_1", "lintangdb_incr_2", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lintangdb_incr_full", "lintangdb_incr_1", "lintangdb_incr_2"}, incr2.Chain)
+	for _, file := range incr2.Files {
+		if file.Name == "test.db" {
+			assert.Equal(t, []int{5}, file.Changed)
+		}
+	}
+	writeGen(t, dm, "test.db", 6, 4) // write setelah backup selesai tidak masuk backup
+
+	_, err = Restore("lintangdb_incr_2", "lintangdb_incr_restored", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	rdm, err := storage.NewDiskManager("lintangdb_incr_restored", 0, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	n, err := rdm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+	for i := 0; i < n; i++ {
+		want := 1
+		switch i {
+		case 3, 7, 10:
+			want = 2
+		case 5:
+			want = 3
+		}
+		assert.Equal(t, want, readGen(t, rdm, "test.db", i), "test.db block %d", i)
+	}
+	assert.Equal(t, 8192, rdm.FileBlockSize("new.db"))
+	assert.Equal(t, 2, readGen(t, rdm, "new.db", 0))
+	assert.NoError(t, rdm.Close())
+
+	// LSN log database baru mulai dari awal lagi: block LSN nya tidak bisa dibandingkan dengan backup lama
+	dm2, err := storage.NewDiskManager("lintangdb_incr_other", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm2, err := log.NewLogManager(dm2, "lintangdb.log")
+	assert.NoError(t, err)
+	_, err = IncrementalBackup(dm2, lm2, "lintangdb_incr_2", "lintangdb_incr_3", storage.WithVFS(fs))
+	assert.ErrorIs(t, err, ErrLSNRegressed)
+}
+
+func TestIncrementalRestoreShrunkAndDeletedFiles(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_shrink", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		writeGen(t, dm, "test.db", i, 1)
+	}
+	writeGen(t, dm, "old.db", 0, 1)
+	_, err = Backup(dm, lm, "lintangdb_shrink_full", storage.WithVFS(fs))
+	assert.NoError(t, err)
+
+	// file diperkecil & dihapus setelah backup base
+	for i := 6; i < 10; i++ {
+		assert.NoError(t, dm.Free(storage.NewBlockID("test.db", i)))
+	}
+	truncated, err := dm.TruncateFree("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, truncated)
+	writeGen(t, dm, "test.db", 2, 2)
+	assert.NoError(t, dm.DeleteFile("old.db"))
+	_, err = IncrementalBackup(dm, lm, "lintangdb_shrink_full", "lintangdb_shrink_incr", storage.WithVFS(fs))
+	assert.NoError(t, err)
+
+	_, err = Restore("lintangdb_shrink_incr", "lintangdb_shrink_restored", storage.WithVFS(fs))
+	assert.NoError(t, err)
+	rdm, err := storage.NewDiskManager("lintangdb_shrink_restored", 0, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	defer rdm.Close()
+	n, err := rdm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	for i := 0; i < n; i++ {
+		want := 1
+		if i == 2 {
+			want = 2
+		}
+		assert.Equal(t, want, readGen(t, rdm, "test.db", i), "test.db block %d", i)
+	}
+	assert.NotContains(t, rdm.Files(), "old.db")
+	_, err = fs.Stat(filepath.Join("lintangdb_shrink_restored", "old.db"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
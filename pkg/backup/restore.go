@@ -0,0 +1,258 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+Restore. restore backup di backupDir ke directory database baru targetDir: copy semua file block backup, lalu replay page image di log tail backup.
+kalau backupDir incremental backup, semua backup di chain nya direstore berurutan: backup full dulu, lalu tiap incremental backup (block yang berubah + log tail nya).
+opts dipakai buat buka DiskManager backup (read-only) & DiskManager target (mis. WithVFS).
+*/
+func Restore(backupDir, targetDir string, opts ...storage.DiskManagerOption) (*Info, error) {
+	src, err := openBackup(backupDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ReadInfo(src.GetVFS(), backupDir)
+	err = errors.Join(err, src.Close())
+	if err != nil {
+		return nil, err
+	}
+	chain := info.Chain
+	if len(chain) == 0 {
+		chain = []string{backupDir}
+	}
+
+	dst, err := storage.NewDiskManager(targetDir, info.BlockSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !dst.IsNew() {
+		return nil, errors.Join(fmt.Errorf("%w: %s", ErrTargetNotEmpty, dst.GetDBDir()), dst.Close())
+	}
+	for _, dir := range chain {
+		err = restoreBackup(dir, dst, opts)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to restore backup %s: %w", dir, err), dst.Close())
+		}
+	}
+	return info, errors.Join(dst.Sync(), dst.Close())
+}
+
+// openBackup. buka directory backup dir read-only.
+func openBackup(dir string, opts []storage.DiskManagerOption) (*storage.DiskManager, error) {
+	return storage.NewDiskManager(dir, 0, slices.Concat(opts, []storage.DiskManagerOption{storage.WithReadOnly(true)})...)
+}
+
+// restoreBackup. restore satu backup di chain ke dst.
+func restoreBackup(dir string, dst *storage.DiskManager, opts []storage.DiskManagerOption) error {
+	src, err := openBackup(dir, opts)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := ReadInfo(src.GetVFS(), dir)
+	if err != nil {
+		return err
+	}
+	if info.Type == Incremental {
+		err = restoreChangedBlocks(src, dst, info)
+	} else {
+		err = restore(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	return replayLog(src, dst)
+}
+
+// restore. copy semua file block backup src ke dst.
+func restore(src, dst *storage.DiskManager) error {
+	for _, name := range src.Files() {
+		if name == LogFile {
+			continue
+		}
+		_, err := copyFile(src, dst, name)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+/*
+restoreChangedBlocks. copy block yang berubah di incremental backup src ke dst. file dibuat / diperbesar / diperkecil (mis. TruncateFree setelah
+backup base) sampai jumlah block nya pas backup. file yang tidak ada di backup (dihapus setelah backup base) dihapus.
+*/
+func restoreChangedBlocks(src, dst *storage.DiskManager, info *Info) error {
+	for _, name := range dst.Files() {
+		if !slices.ContainsFunc(info.Files, func(file FileInfo) bool { return file.Name == name }) {
+			err := dst.DeleteFile(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range info.Files {
+		err := dst.CreateFile(file.Name, file.BlockSize)
+		if err != nil {
+			return err
+		}
+		numBlocks, err := dst.BlockLength(file.Name)
+		if err != nil {
+			return err
+		}
+		for ; numBlocks < file.Blocks; numBlocks++ {
+			_, err = dst.Append(file.Name)
+			if err != nil {
+				return err
+			}
+		}
+		err = dst.Truncate(file.Name, file.Blocks)
+		if err != nil {
+			return err
+		}
+		page := storage.NewPage(file.BlockSize)
+		for _, blockNum := range file.Changed {
+			blockID := storage.NewBlockID(file.Name, blockNum)
+			err = src.Read(blockID, page)
+			if err != nil {
+				return fmt.Errorf("failed to restore %s block %d: %w", file.Name, blockNum, err)
+			}
+			err = dst.Write(blockID, page)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replayLog. replay page image di log tail backup src ke dst.
+func replayLog(src, dst *storage.DiskManager) error {
+	records, err := readLog(src, LogFile)
+	if err != nil {
+		return err
+	}
+	_, err = replay(dst, records, func(int, []byte) bool { return false })
+	return err
+}
+
+/*
+replay. tulis page image di log record records ke dm, berhenti di record pertama yang stop nya true.
+return jumlah record yang direplay. page image satu block yang terpotong stop tidak ditulis (jumlah record tidak termasuk record page image nya).
+*/
+func replay(dm *storage.DiskManager, records [][]byte, stop func(i int, record []byte) bool) (int, error) {
+	r := &pageReplayer{dm: dm}
+	for i, record := range records {
+		if stop(i, record) {
+			if r.data != nil && isContinuation(record) {
+				return r.start, nil
+			}
+			return i, r.flush()
+		}
+		err := r.add(i, record)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(records), r.flush()
+}
+
+// isContinuation. return true kalau record potongan page image setelah potongan pertama.
+func isContinuation(record []byte) bool {
+	if log.GetRecordType(record) != log.PageImageRecord {
+		return false
+	}
+	img, err := log.DecodePageImage(record)
+	return err == nil && img.Offset > 0
+}
+
+// pageReplayer. gabung potongan page image yang berurutan (log lama, sebelum LogManager.LogPage menulis page image utuh) jadi isi block utuh sebelum ditulis.
+type pageReplayer struct {
+	dm      *storage.DiskManager
+	blockID storage.BlockID
+	data    []byte // isi block yang sedang digabung, nil kalau tidak ada
+	start   int    // index record potongan pertama
+}
+
+// add. tambah log record ke replay. record selain page image menutup page image sebelumnya.
+func (r *pageReplayer) add(i int, record []byte) error {
+	if log.GetRecordType(record) != log.PageImageRecord {
+		return r.flush()
+	}
+	img, err := log.DecodePageImage(record)
+	if err != nil {
+		return err
+	}
+	if img.Offset == 0 {
+		err = r.flush()
+		if err != nil {
+			return err
+		}
+		r.blockID, r.data, r.start = img.BlockID, img.Data, i
+		return nil
+	}
+	if r.data == nil || img.BlockID != r.blockID || img.Offset != len(r.data) {
+		return fmt.Errorf("%w: page image of block %d of %s at offset %d without previous part", log.ErrCorruptRecord, img.BlockID.GetBlockNum(), img.BlockID.GetFilename(), img.Offset)
+	}
+	r.data = append(r.data, img.Data...)
+	return nil
+}
+
+// flush. tulis page image yang sedang digabung.
+func (r *pageReplayer) flush() error {
+	if r.data == nil {
+		return nil
+	}
+	err := applyPage(r.dm, r.blockID, r.data)
+	r.data = nil
+	return err
+}
+
+// readLog. read semua log record di log file logFile, urut dari yang terdahulu.
+func readLog(dm *storage.DiskManager, logFile string) ([][]byte, error) {
+	numBlocks, err := dm.BlockLength(logFile)
+	if err != nil || numBlocks == 0 {
+		return nil, err
+	}
+	lit, err := log.NewLogIterator(dm, storage.NewBlockID(logFile, numBlocks-1))
+	if err != nil {
+		return nil, err
+	}
+	var records [][]byte
+	for record := range lit.IterateLog() {
+		records = append(records, record)
+	}
+	if lit.GetError() != nil {
+		return nil, lit.GetError()
+	}
+	slices.Reverse(records)
+	return records, nil
+}
+
+// applyPage. tulis isi block blockID ke dm. file dibuat (block size = ukuran isi block) & diperbesar kalau block belum ada (block di append setelah file dicopy).
+func applyPage(dm *storage.DiskManager, blockID storage.BlockID, contents []byte) error {
+	filename := blockID.GetFilename()
+	err := dm.CreateFile(filename, len(contents))
+	if err != nil {
+		return err
+	}
+	numBlocks, err := dm.BlockLength(filename)
+	if err != nil {
+		return err
+	}
+	for ; numBlocks <= blockID.GetBlockNum(); numBlocks++ {
+		_, err = dm.Append(filename)
+		if err != nil {
+			return err
+		}
+	}
+	return dm.Write(blockID, storage.NewPageFromByteSlice(contents))
+}
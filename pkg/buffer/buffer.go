@@ -1,14 +1,11 @@
 package buffer
 
-import "github.com/lintang-b-s/go-simpledb/pkg/storage"
+import (
+	"errors"
+	"io"
 
-type DiskManager interface {
-	Read(blockID storage.BlockID, page *storage.Page) error
-	Write(blockID storage.BlockID, page *storage.Page) error
-	Append(fileName string) (storage.BlockID, error)
-	BlockLength(fileName string) (int, error)
-	BlockSize() int
-}
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
 
 type LogManager interface {
 	Flush(lsn int) error
@@ -17,7 +14,7 @@ type LogManager interface {
 
 // Buffer . menyimpan page yang diambil dari disk  ke memori selama status nya masih pinned (pins > 0). jika di unpin (pins = 0) maka page akan dijadwalkan untuk diremove dari buffer pool & diwrite ke disk.
 type Buffer struct {
-	diskManager    DiskManager
+	diskManager    storage.BlockManager
 	logManager     LogManager
 	contents       *storage.Page // page yang disimpan di buffer.
 	blockID        storage.BlockID // blockID dari page. (buat nentuin offset pas write data page ke file)
@@ -28,7 +25,7 @@ type Buffer struct {
 	isDirty bool // dirty flag buat nandain kalo page diupdate (isDirty = true -> harus diwrite ke disk sebelum di remove dari buffer pool)
 }
 
-func NewBuffer(diskManager DiskManager, logManager LogManager) *Buffer {
+func NewBuffer(diskManager storage.BlockManager, logManager LogManager) *Buffer {
 	buf := &Buffer{
 		diskManager:    diskManager,
 		logManager:     logManager,
@@ -59,37 +56,60 @@ func (buf *Buffer) getTransactionNum() int {
 	return buf.transactionNum
 }
 
-// assignToBlock. read block (blockID) ke content dari buffer.contents
-func (buf *Buffer) assignToBlock(blockID storage.BlockID) error {
+// assignToBlock. read block (blockID) ke content dari buffer.contents.
+// read block baru disubmit async dulu ke page baru, jadi flush page lama (kalau dirty) overlap dengan read block baru.
+// kalau allowMissing, block yang belum ada di disk (io.EOF) jadi page kosong.
+func (buf *Buffer) assignToBlock(blockID storage.BlockID, allowMissing bool) error {
+	contents := storage.NewPage(buf.diskManager.FileBlockSize(blockID.GetFilename())) // tiap file bisa punya block size sendiri
+	req := buf.diskManager.ReadAsync(blockID, contents) // read block dari disk ke page baru
+
 	err := buf.flush() // flush log record dan data buffer yang sebelumnya
-	if err != nil {
-		return err
-	}
-	buf.blockID = blockID
-	err = buf.diskManager.Read(blockID, buf.contents)// read block dari disk ke buf.contents
+	readErr := req.Wait()
 	if err != nil {
 		return err
 	}
 	buf.pins = 0 // reset pins
+	if allowMissing && errors.Is(readErr, io.EOF) {
+		readErr = nil
+	}
+	if readErr != nil {
+		// page lama sudah diflush, isi block yang gagal dibaca (mis. checksum mismatch) tidak dipasang ke buffer
+		buf.blockID = storage.BlockID{}
+		buf.ResetMemory()
+		return readErr
+	}
+	buf.blockID = blockID
+	buf.contents = contents
 	return nil
 }
 
-// flush. write data buffer & log record ke disk jika transactionNum >= 0
+// flush. write data buffer & log record ke disk jika buffer dirty atau transactionNum >= 0. block diwrite lewat doublewrite buffer.
 func (buf *Buffer) flush() error {
-	if buf.transactionNum >= 0 {
+	if buf.needsFlush() {
 		err := buf.logManager.Flush(buf.lsn)
 		if err != nil {
 			return err
 		}
-		err = buf.diskManager.Write(buf.blockID, buf.contents)
+		err = buf.diskManager.WriteBlocks([]storage.BlockWrite{buf.blockWrite()})
 		if err != nil {
 			return err
 		}
 		buf.transactionNum = -1
+		buf.isDirty = false
 	}
 	return nil
 }
 
+// needsFlush. return true kalau isi buffer harus diwrite ke disk.
+func (buf *Buffer) needsFlush() bool {
+	return buf.isDirty || buf.transactionNum >= 0
+}
+
+// blockWrite. return block & page dari buffer buat diwrite ke disk.
+func (buf *Buffer) blockWrite() storage.BlockWrite {
+	return storage.BlockWrite{BlockID: buf.blockID, Page: buf.contents}
+}
+
 // incrementPin. increment pin count
 func (buf *Buffer) incrementPin() {
 	buf.pins++
@@ -16,11 +16,13 @@ type BufferPoolManager struct {
 	freeList     []int                   // list frame yang tidak hold any page data.
 	replacer     *LRUReplacer            // LRU replacer buat evict least recently used page dari buffer pool.
 	latch        *sync.Mutex
-	nextBlockID  int
+	diskManager  storage.BlockManager
+	relocating   map[storage.BlockID]*relocation // block yang sedang dipindah Vacuum
+	relocated    *sync.Cond                      // broadcast setiap relocation selesai, pakai latch
 }
 
 // NewBufferPoolManager. initialize buffer pool manager.
-func NewBufferPoolManager(numBuffers int, diskManager DiskManager,
+func NewBufferPoolManager(numBuffers int, diskManager storage.BlockManager,
 	logManager LogManager) *BufferPoolManager {
 	bufferPool := make([]*Buffer, numBuffers)
 	for i := 0; i < numBuffers; i++ {
@@ -32,23 +34,48 @@ func NewBufferPoolManager(numBuffers int, diskManager DiskManager,
 		fl[i] = i //
 	}
 
+	latch := &sync.Mutex{}
 	return &BufferPoolManager{bufferPool: bufferPool, numAvailable: numBuffers,
 		poolSize: numBuffers, bufferTable: make(map[storage.BlockID]int), freeList: fl,
-		latch: &sync.Mutex{}, replacer: NewLRUReplacer(numBuffers), nextBlockID: 0}
+		latch: latch, replacer: NewLRUReplacer(numBuffers), diskManager: diskManager,
+		relocating: make(map[storage.BlockID]*relocation), relocated: sync.NewCond(latch)}
 }
 
 func (bpm *BufferPoolManager) getBufferAvailable() int {
 	return bpm.numAvailable
 }
 
-// flushAll. flush semua buffer yang terkait dengan transactionNum.
-func (bpm *BufferPoolManager) flushAll(transactionNum int) {
+// flushAll. flush semua buffer yang terkait dengan transactionNum. semua block diwrite sekaligus dalam satu batch doublewrite.
+func (bpm *BufferPoolManager) flushAll(transactionNum int) error {
+	var (
+		buffers []*Buffer
+		writes  []storage.BlockWrite
+		maxLSN  = -1
+	)
 	for _, buffer := range bpm.bufferPool {
-		if buffer.getTransactionNum() == transactionNum {
-			buffer.flush()
-			buffer.setDirty(false)
+		if buffer.getTransactionNum() == transactionNum && buffer.needsFlush() {
+			buffers = append(buffers, buffer)
+			writes = append(writes, buffer.blockWrite())
+			maxLSN = max(maxLSN, buffer.lsn)
 		}
 	}
+	if len(buffers) == 0 {
+		return nil
+	}
+
+	err := buffers[0].logManager.Flush(maxLSN) // WAL: flush log record sebelum write data block
+	if err != nil {
+		return err
+	}
+	err = buffers[0].diskManager.WriteBlocks(writes)
+	if err != nil {
+		return err
+	}
+	for _, buffer := range buffers {
+		buffer.transactionNum = -1
+		buffer.setDirty(false)
+	}
+	return nil
 }
 
 // UnpinPage. unpin page/buffer dengan blockID. page yang diunpin akan di evict dari buffer pool & write ke disk jika dirty page.
@@ -91,6 +118,10 @@ FetchPage. fetch page dengan block id dari buffer pool. kalau page tidak ada di
 func (bpm *BufferPoolManager) FetchPage(blockID storage.BlockID) (*storage.Page, error) {
 	bpm.latch.Lock()
 	defer bpm.latch.Unlock()
+	err := bpm.waitRelocation(blockID) // page yang sedang dipindah Vacuum baru bisa di pin setelah selesai
+	if err != nil {
+		return nil, err
+	}
 
 	item, ok := bpm.bufferTable[blockID]
 	var frameID int
@@ -122,18 +153,8 @@ func (bpm *BufferPoolManager) FetchPage(blockID storage.BlockID) (*storage.Page,
 
 	replacedBuffer := bpm.bufferPool[frameID] // least recently used buffer/page
 
-	if replacedBuffer.getIsDirty() {
-		// kalau page yang di evict dari buffer pool adalah dirty, flush page tsb
-		replacedBuffer.flush()
-		replacedBuffer.setDirty(false)
-	}
-
-	pageBlockID := replacedBuffer.getBlockID()
-	delete(bpm.bufferTable, pageBlockID)
-
-	bpm.bufferTable[blockID] = frameID // put blockID ke pageTable
-
-	err := replacedBuffer.assignToBlock(blockID) // assign buffer ke paeg yang baru & set pin = 0
+	// assign buffer ke paeg yang baru & set pin = 0. kalau page yang di evict dari buffer pool dirty, page tsb di flush overlap dengan read page baru
+	err = bpm.assignFrame(frameID, blockID, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to assign buffer to block %w", err)
 	}
@@ -143,10 +164,41 @@ func (bpm *BufferPoolManager) FetchPage(blockID storage.BlockID) (*storage.Page,
 	return replacedBuffer.getContents(), nil
 }
 
+/*
+assignFrame. read block blockID ke frame frameID & put blockID ke bufferTable. page lama di frame (kalau ada) dihapus dari bufferTable.
+kalau gagal, blockID tidak masuk bufferTable: frame balik ke replacer kalau page lama masih di frame (flush page lama gagal), selain itu frame balik ke freeList.
+caller harus hold bpm.latch & frame sudah diambil dari freeList / replacer.
+*/
+func (bpm *BufferPoolManager) assignFrame(frameID int, blockID storage.BlockID, allowMissing bool) error {
+	buffer := bpm.bufferPool[frameID]
+	oldBlockID := buffer.getBlockID()
+	id, hasPage := bpm.bufferTable[oldBlockID]
+	hasPage = hasPage && id == frameID
+
+	err := buffer.assignToBlock(blockID, allowMissing)
+	if err != nil && hasPage && buffer.getBlockID() == oldBlockID {
+		bpm.replacer.Unpin(frameID)
+		return err
+	}
+	if hasPage {
+		delete(bpm.bufferTable, oldBlockID)
+	}
+	if err != nil {
+		bpm.freeList = append(bpm.freeList, frameID)
+		return err
+	}
+	bpm.bufferTable[blockID] = frameID
+	return nil
+}
+
 // PinPage. pin page dengan block id & put page di buffer pool. buffer/page yang di pin tidak akan dihapus dari buffer pool.
 func (bpm *BufferPoolManager) PinPage(blockID storage.BlockID) (*Buffer, error) {
 	bpm.latch.Lock()
 	defer bpm.latch.Unlock()
+	err := bpm.waitRelocation(blockID)
+	if err != nil {
+		return nil, err
+	}
 
 	allPinned := true
 	for i := 0; i < bpm.poolSize; i++ {
@@ -189,11 +241,11 @@ func (bpm *BufferPoolManager) PinPage(blockID storage.BlockID) (*Buffer, error)
 
 	replacedBuffer := bpm.bufferPool[frameID]
 
-	replacedBuffer.assignToBlock(blockID) // assign buffer ke paeg yang baru & set pin = 0
-	replacedBuffer.incrementPin()         // incerment pin jadi 1
-
-	bpm.bufferTable[blockID] = frameID
-	bpm.bufferPool[frameID] = replacedBuffer
+	err = bpm.assignFrame(frameID, blockID, true) // assign buffer ke paeg yang baru & set pin = 0. block yang belum ada di disk jadi page kosong
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign buffer to block %w", err)
+	}
+	replacedBuffer.incrementPin() // incerment pin jadi 1
 
 	bpm.replacer.Pin(frameID) // pin frameID biar tidka di evict dari buffer pool
 
@@ -201,7 +253,7 @@ func (bpm *BufferPoolManager) PinPage(blockID storage.BlockID) (*Buffer, error)
 }
 
 /*
-NewPage. Allocates a new page on disk. dan put new buffer/page ke buffer pool.
+NewPage. Allocates a new page on disk (pakai block yang sudah di DeletePage dulu sebelum file diperbesar). dan put new buffer/page ke buffer pool.
 ,frameID baru di ambil dari freelist or  dari evict least recently used page dari buffer pool. dan replace buffer least recently used di buffer pool dengan page blockID.
 */
 func (bpm *BufferPoolManager) NewPage(blockID *storage.BlockID) (*storage.Page, error) {
@@ -247,50 +299,39 @@ func (bpm *BufferPoolManager) NewPage(blockID *storage.BlockID) (*storage.Page,
 
 	replacedBuffer := bpm.bufferPool[frameID]
 
-	newPage := storage.NewPage(pkg.MAX_PAGE_SIZE)                    //create new page
-	*blockID = storage.NewBlockID(pkg.DB_FILE_NAME, bpm.nextBlockID) // create new blockID
-	bpm.nextBlockID++
-
-	replacedBuffer.assignToBlock(*blockID) // assign buffer ke paeg yang baru & set pin = 0
-	replacedBuffer.incrementPin()          // incerment pin jadi 1
+	newBlockID, err := bpm.diskManager.Allocate(pkg.DB_FILE_NAME) // alokasi block baru di disk
+	if err != nil {
+		bpm.freeList = append(bpm.freeList, frameID)
+		return nil, fmt.Errorf("failed to allocate new page %w", err)
+	}
+	*blockID = newBlockID
 
-	bpm.bufferTable[*blockID] = frameID
-	bpm.bufferPool[frameID] = replacedBuffer
+	err = bpm.assignFrame(frameID, *blockID, false) // assign buffer ke paeg yang baru & set pin = 0
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign buffer to block %w", err)
+	}
+	replacedBuffer.incrementPin() // incerment pin jadi 1
 
 	bpm.replacer.Pin(frameID) // pin frameID biar tidka di evict dari buffer pool
 
-	return newPage, nil
+	return replacedBuffer.getContents(), nil
 }
 
-// DeletePage. Removes a page from the database, both on disk and in memory.
+// DeletePage. Removes a page from the database, both on disk and in memory. block di disk ditandai free di space map & dipakai lagi oleh NewPage.
 func (bpm *BufferPoolManager) DeletePage(blockID storage.BlockID) bool {
 	bpm.latch.Lock()
 	defer bpm.latch.Unlock()
 
 	frameID, ok := bpm.bufferTable[blockID]
-	if !ok {
-		// page tidak ada di buffer pool
-		return true
-	}
-
-	if bpm.bufferPool[frameID].getPinCount() > 0 {
-		// page masih di pin
-		return false
-	}
-
-	deletedPage := bpm.bufferPool[frameID]
+	if ok {
+		if bpm.bufferPool[frameID].getPinCount() > 0 {
+			// page masih di pin
+			return false
+		}
 
-	if deletedPage.getIsDirty() {
-		// kalau page yang di evict dari buffer pool adalah dirty, flush page tsb
-		deletedPage.flush()
-		deletedPage.setDirty(false)
+		bpm.dropFrame(blockID, frameID) // page dihapus, tidak perlu diwrite ke disk
 	}
 
-	delete(bpm.bufferTable, blockID)
-
-	bpm.replacer.Remove(frameID)
-	deletedPage.ResetMemory()
-
-	bpm.freeList = append(bpm.freeList, frameID)
-	return true
+	err := bpm.diskManager.Free(blockID) // free block di disk
+	return err == nil
 }
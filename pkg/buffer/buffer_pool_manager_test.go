@@ -3,8 +3,10 @@ package buffer
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/lintang-b-s/go-simpledb/pkg"
 	"github.com/lintang-b-s/go-simpledb/pkg/log"
 	"github.com/lintang-b-s/go-simpledb/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +22,10 @@ func cleanDB() {
 func TestBufferManager(t *testing.T) {
 	cleanDB()
 
-	dm := storage.NewDiskManager("lintangdb", 4096)
+	dm, err := storage.NewDiskManager("lintangdb", 4096)
+	if err != nil {
+		t.Fatalf("Error creating disk manager: %s", err)
+	}
 	lm, err := log.NewLogManager(dm, "lintangdb.log")
 	if err != nil {
 		t.Errorf("Error creating log manager: %s", err)
@@ -110,4 +115,105 @@ func TestBufferManager(t *testing.T) {
 		}
 
 	})
+
+	t.Run("deleted page dipakai lagi oleh new page - buffer pool manager", func(t *testing.T) {
+		bm := NewBufferPoolManager(5, dm, lm)
+
+		var first, second storage.BlockID
+		page, err := bm.NewPage(&first)
+		assert.NoError(t, err)
+		page.PutString(0, "lintang")
+		assert.True(t, bm.UnpinPage(first, true))
+
+		_, err = bm.NewPage(&second)
+		assert.NoError(t, err)
+		assert.Equal(t, first.GetBlockNum()+1, second.GetBlockNum())
+
+		// page yang masih di pin tidak bisa dihapus
+		assert.False(t, bm.DeletePage(second))
+		assert.True(t, bm.UnpinPage(second, false))
+
+		assert.True(t, bm.DeletePage(first))
+		assert.False(t, bm.DeletePage(first)) // double free
+
+		var reused storage.BlockID
+		page, err = bm.NewPage(&reused)
+		assert.NoError(t, err)
+		assert.Equal(t, first, reused)
+		assert.Equal(t, "", page.GetString(0))
+	})
+}
+
+func TestBufferManagerBlockSize(t *testing.T) {
+	os.RemoveAll("lintangdb_bs")
+	defer os.RemoveAll("lintangdb_bs")
+
+	dm, err := storage.NewDiskManager("lintangdb_bs", 16384, storage.WithFileBlockSize(8192, "lintangdb.log"))
+	assert.NoError(t, err)
+	defer dm.Close()
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	bm := NewBufferPoolManager(2, dm, lm)
+
+	// page baru berukuran block size database
+	var blockIDs [3]storage.BlockID
+	for i := range blockIDs {
+		page, err := bm.NewPage(&blockIDs[i])
+		assert.NoError(t, err)
+		assert.Len(t, page.Contents(), 16384)
+		page.PutString(16000, fmt.Sprintf("page %d", i))
+		assert.True(t, bm.UnpinPage(blockIDs[i], true))
+	}
+	for i, blockID := range blockIDs {
+		page, err := bm.FetchPage(blockID)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("page %d", i), page.GetString(16000))
+		assert.True(t, bm.UnpinPage(blockID, false))
+	}
+
+	// log file pakai block size nya sendiri
+	assert.NoError(t, lm.Flush2())
+	fi, err := os.Stat(filepath.Join("lintangdb_bs", "lintangdb.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8192), fi.Size())
+}
+
+func TestFetchCorruptedPage(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_corrupt", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	defer dm.Close()
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	bm := NewBufferPoolManager(2, dm, lm)
+
+	var blockID storage.BlockID
+	page, err := bm.NewPage(&blockID)
+	assert.NoError(t, err)
+	page.PutString(0, "lintang")
+	assert.True(t, bm.UnpinPage(blockID, true))
+
+	// evict page dari buffer pool lalu corrupt block nya langsung di file
+	for i := 0; i < 2; i++ {
+		var other storage.BlockID
+		_, err = bm.NewPage(&other)
+		assert.NoError(t, err)
+		assert.True(t, bm.UnpinPage(other, false))
+	}
+	f, err := fs.OpenFile(filepath.Join("lintangdb_corrupt", pkg.DB_FILE_NAME), os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, int64(blockID.GetBlockNum()*4096+5))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	// fetch kedua tidak boleh return isi block yang corrupt & frame tidak bocor
+	for i := 0; i < 2; i++ {
+		_, err = bm.FetchPage(blockID)
+		assert.ErrorIs(t, err, storage.ErrChecksumMismatch)
+	}
+	var blockIDs [2]storage.BlockID
+	for i := range blockIDs {
+		_, err = bm.NewPage(&blockIDs[i])
+		assert.NoError(t, err)
+	}
 }
@@ -0,0 +1,138 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+// ErrPageRelocated. dikembalikan FetchPage/PinPage kalau page dipindah Vacuum selama menunggu: referensi ke page sudah diupdate relocate, baca ulang referensinya.
+var ErrPageRelocated = errors.New("page was relocated")
+
+// RelocateFunc. dipanggil Vacuum setelah isi block from dicopy ke block to. caller update semua referensi ke from (mis. pointer di page index/heap) jadi to.
+// relocate tidak boleh fetch/pin page from (pin page from ditahan sampai relocate selesai).
+type RelocateFunc func(from, to storage.BlockID) error
+
+// VacuumStats. hasil Vacuum.
+type VacuumStats struct {
+	Moved     int // jumlah block live yang dipindah ke depan file
+	Truncated int // jumlah block free yang dibuang dari akhir file
+}
+
+// relocation. block yang sedang dipindah Vacuum. FetchPage/PinPage block nya menunggu sampai done.
+type relocation struct {
+	done  bool
+	moved bool // block dipindah & sudah di Free
+}
+
+/*
+Vacuum. compact file fileName: block live di akhir file dipindah ke block free di depan file, lalu block free di akhir file di truncate.
+jalan online: page lain tetap bisa di fetch/pin selama vacuum, page yang sedang di pin tidak dipindah. dari block dipilih sampai di Free, pin baru ke block itu
+menunggu (lalu return ErrPageRelocated), jadi tidak ada write yang hilang. relocate boleh pakai buffer pool (dipanggil tanpa hold latch).
+*/
+func (bpm *BufferPoolManager) Vacuum(fileName string, relocate RelocateFunc) (VacuumStats, error) {
+	var stats VacuumStats
+	for {
+		var prepared []storage.BlockID
+		from, to, ok, err := bpm.diskManager.RelocateBlock(fileName, func(from storage.BlockID) (bool, error) {
+			ok, err := bpm.prepareRelocate(from)
+			if ok {
+				prepared = append(prepared, from)
+			}
+			return ok, err
+		})
+		for _, blockID := range prepared {
+			if !ok || blockID != from {
+				bpm.finishRelocate(blockID, false) // block tidak jadi dipindah
+			}
+		}
+		if err != nil {
+			return stats, err
+		}
+		if !ok {
+			break
+		}
+
+		if relocate != nil {
+			err = relocate(from, to)
+			if err != nil {
+				// referensi masih ke from, copy di to dibuang
+				err = errors.Join(err, bpm.diskManager.Free(to))
+				bpm.finishRelocate(from, false)
+				return stats, err
+			}
+		}
+
+		err = bpm.diskManager.Free(from)
+		bpm.finishRelocate(from, true)
+		if err != nil {
+			return stats, fmt.Errorf("failed to free relocated block %d of file %s: %w", from.GetBlockNum(), from.GetFilename(), err)
+		}
+		stats.Moved++
+	}
+
+	truncated, err := bpm.diskManager.TruncateFree(fileName)
+	stats.Truncated = truncated
+	return stats, err
+}
+
+// prepareRelocate. dipanggil sebelum block from dicopy. page from yang sedang di pin tidak dipindah, page yang tidak di pin di flush & dikeluarkan dari buffer pool.
+// kalau return true, pin baru ke from menunggu sampai finishRelocate.
+func (bpm *BufferPoolManager) prepareRelocate(from storage.BlockID) (bool, error) {
+	bpm.latch.Lock()
+	defer bpm.latch.Unlock()
+	if frameID, ok := bpm.bufferTable[from]; ok {
+		buffer := bpm.bufferPool[frameID]
+		if buffer.getPinCount() > 0 {
+			return false, nil
+		}
+		err := buffer.flush()
+		if err != nil {
+			return false, err
+		}
+		bpm.dropFrame(from, frameID)
+	}
+	bpm.relocating[from] = &relocation{}
+	return true, nil
+}
+
+// finishRelocate. lepas pin yang menunggu block blockID. moved true kalau block sudah dipindah & di Free.
+func (bpm *BufferPoolManager) finishRelocate(blockID storage.BlockID, moved bool) {
+	bpm.latch.Lock()
+	defer bpm.latch.Unlock()
+	if r, ok := bpm.relocating[blockID]; ok {
+		r.done, r.moved = true, moved
+		delete(bpm.relocating, blockID)
+		bpm.relocated.Broadcast()
+	}
+}
+
+// waitRelocation. tunggu kalau block blockID sedang dipindah Vacuum. return ErrPageRelocated kalau block nya jadi dipindah. caller harus hold bpm.latch.
+func (bpm *BufferPoolManager) waitRelocation(blockID storage.BlockID) error {
+	r, ok := bpm.relocating[blockID]
+	if !ok {
+		return nil
+	}
+	for !r.done {
+		bpm.relocated.Wait()
+	}
+	if r.moved {
+		return fmt.Errorf("%w: file %s block %d", ErrPageRelocated, blockID.GetFilename(), blockID.GetBlockNum())
+	}
+	return nil
+}
+
+// dropFrame. keluarkan page blockID dari buffer pool tanpa write ke disk. caller harus hold bpm.latch.
+func (bpm *BufferPoolManager) dropFrame(blockID storage.BlockID, frameID int) {
+	buffer := bpm.bufferPool[frameID]
+	buffer.setDirty(false)
+	buffer.transactionNum = -1
+
+	delete(bpm.bufferTable, blockID)
+
+	bpm.replacer.Remove(frameID)
+	buffer.ResetMemory()
+
+	bpm.freeList = append(bpm.freeList, frameID)
+}
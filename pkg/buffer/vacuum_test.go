@@ -0,0 +1,192 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg"
+	"github.com/lintang-b-s/go-simpledb/pkg/log"
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVacuum(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_vacuum", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	bm := NewBufferPoolManager(4, dm, lm)
+
+	blocks := make([]storage.BlockID, 12)
+	for i := range blocks {
+		page, err := bm.NewPage(&blocks[i])
+		assert.NoError(t, err)
+		page.PutString(0, fmt.Sprintf("lintang%d", i))
+		assert.True(t, bm.UnpinPage(blocks[i], true))
+	}
+	for i := 0; i < 6; i++ {
+		assert.True(t, bm.DeletePage(blocks[i]))
+	}
+
+	// page 11 di pin selama vacuum: tidak dipindah
+	_, err = bm.FetchPage(blocks[11])
+	assert.NoError(t, err)
+
+	// reader lain tetap bisa fetch page selama vacuum
+	var (
+		wg       sync.WaitGroup
+		refLatch sync.Mutex
+		refs     = map[int]storage.BlockID{} // referensi page i -> block nya
+	)
+	for i := 6; i < 12; i++ {
+		refs[i] = blocks[i]
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 200; n++ {
+			refLatch.Lock()
+			blockID := refs[6+n%6]
+			refLatch.Unlock()
+			page, err := bm.FetchPage(blockID)
+			if errors.Is(err, ErrPageRelocated) {
+				continue // page dipindah selama menunggu: referensi nya dibaca ulang
+			}
+			assert.NoError(t, err)
+			if err == nil {
+				assert.Equal(t, fmt.Sprintf("lintang%d", 6+n%6), page.GetString(0))
+				bm.UnpinPage(blockID, false)
+			}
+		}
+	}()
+
+	stats, err := bm.Vacuum(pkg.DB_FILE_NAME, func(from, to storage.BlockID) error {
+		refLatch.Lock()
+		defer refLatch.Unlock()
+		for i, blockID := range refs {
+			if blockID == from {
+				refs[i] = to
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	wg.Wait()
+	assert.True(t, bm.UnpinPage(blocks[11], false))
+
+	assert.Equal(t, 5, stats.Moved)
+	length, err := dm.BlockLength(pkg.DB_FILE_NAME)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, length) // page 11 yang di pin tetap di akhir file
+
+	stats, err = bm.Vacuum(pkg.DB_FILE_NAME, func(from, to storage.BlockID) error {
+		for i, blockID := range refs {
+			if blockID == from {
+				refs[i] = to
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Moved)
+	assert.Equal(t, 6, stats.Truncated)
+	length, err = dm.BlockLength(pkg.DB_FILE_NAME)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, length)
+
+	for i, blockID := range refs {
+		assert.Less(t, blockID.GetBlockNum(), 6)
+		page, err := bm.FetchPage(blockID)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("lintang%d", i), page.GetString(0))
+		bm.UnpinPage(blockID, false)
+	}
+}
+
+func TestVacuumConcurrentWriter(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_vacuum_writer", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := log.NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	bm := NewBufferPoolManager(4, dm, lm)
+
+	blocks := make([]storage.BlockID, 24)
+	for i := range blocks {
+		page, err := bm.NewPage(&blocks[i])
+		assert.NoError(t, err)
+		page.PutString(0, fmt.Sprintf("lintang%d-0", i))
+		assert.True(t, bm.UnpinPage(blocks[i], true))
+	}
+	for i := 0; i < 12; i++ {
+		assert.True(t, bm.DeletePage(blocks[i]))
+	}
+
+	// writer update page 12-23 terus selama vacuum: tidak ada write yang hilang walaupun page nya dipindah
+	var (
+		wg       sync.WaitGroup
+		refLatch sync.Mutex
+		refs     = map[int]storage.BlockID{}
+		versions = map[int]int{}
+		stop     = make(chan struct{})
+	)
+	for i := 12; i < 24; i++ {
+		refs[i] = blocks[i]
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			i := 12 + n%12
+			refLatch.Lock()
+			blockID := refs[i]
+			refLatch.Unlock()
+			page, err := bm.FetchPage(blockID)
+			if errors.Is(err, ErrPageRelocated) {
+				continue
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			versions[i]++
+			page.PutString(0, fmt.Sprintf("lintang%d-%d", i, versions[i]))
+			assert.True(t, bm.UnpinPage(blockID, true))
+		}
+	}()
+
+	vacuum := func() VacuumStats {
+		stats, err := bm.Vacuum(pkg.DB_FILE_NAME, func(from, to storage.BlockID) error {
+			refLatch.Lock()
+			defer refLatch.Unlock()
+			for i, blockID := range refs {
+				if blockID == from {
+					refs[i] = to
+				}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		return stats
+	}
+	stats := vacuum()
+	close(stop)
+	wg.Wait()
+	// page yang kebetulan sedang di pin writer dilewati, dipindah setelah writer berhenti
+	rest := vacuum()
+	assert.Equal(t, 12, stats.Moved+rest.Moved)
+	assert.Equal(t, 12, stats.Truncated+rest.Truncated)
+
+	for i, blockID := range refs {
+		assert.Less(t, blockID.GetBlockNum(), 12)
+		page, err := bm.FetchPage(blockID)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("lintang%d-%d", i, versions[i]), page.GetString(0))
+		bm.UnpinPage(blockID, false)
+	}
+}
@@ -1,6 +1,8 @@
 package pkg
 
 const (
-	MAX_PAGE_SIZE = 4096
-	DB_FILE_NAME  = "test.db"
+	DEFAULT_PAGE_SIZE = 4096  // block size database baru kalau tidak ditentukan
+	MIN_PAGE_SIZE     = 4096  // block size minimal (kelipatan alignment direct I/O)
+	MAX_PAGE_SIZE     = 65536 // block size maksimal
+	DB_FILE_NAME      = "test.db"
 )
@@ -0,0 +1,36 @@
+package log
+
+import (
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+chunk. log record yang tidak muat di satu block log dipotong jadi beberapa chunk di block-block log yang berurutan (bisa beda segment).
+setiap chunk disimpan seperti record biasa ([panjang (4)] [isi]), tapi 2 bit atas panjang dipakai buat flag continuation header:
+
+	chunkContinued    chunk pertama / tengah: record lanjut di chunk berikutnya. selalu record terakhir di block nya (di offset boundary).
+	chunkContinuation chunk tengah / terakhir: lanjutan chunk di block sebelumnya. selalu record pertama di block nya (paling kanan).
+
+chunk tengah memenuhi satu block sendirian (dua flag nya set). record yang muat di satu block tidak punya flag, jadi format log lama tetap terbaca.
+LSN record yang dipotong = LSN chunk pertama. LogIterator & ForwardLogIterator menggabungkan chunk jadi record utuh;
+chunk yang pasangannya tidak ada (mis. crash sebelum block chunk berikutnya diwrite) di-skip.
+*/
+const (
+	chunkContinued    = 1 << 30
+	chunkContinuation = 1 << 29
+	chunkLengthMask   = chunkContinuation - 1 // panjang maksimal satu log record
+)
+
+// readChunk. return isi & flag chunk (atau record) di offset pos page.
+func readChunk(page *storage.Page, pos int) ([]byte, int) {
+	header := page.GetInt(pos)
+	length := header & chunkLengthMask
+	data := make([]byte, length)
+	copy(data, page.Contents()[pos+4:pos+4+length])
+	return data, header &^ chunkLengthMask
+}
+
+// chunkEnd. return offset setelah chunk (atau record) di offset pos page.
+func chunkEnd(page *storage.Page, pos int) int {
+	return pos + 4 + page.GetInt(pos)&chunkLengthMask
+}
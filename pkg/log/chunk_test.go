@@ -0,0 +1,119 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// spanningRecords. return log record selang-seling kecil & besar (sampai beberapa block log 4096 byte).
+func spanningRecords() [][]byte {
+	var records [][]byte
+	for i := 0; i < 20; i++ {
+		records = append(records, createLogMessage(fmt.Sprintf("lintang %d", i)))
+		records = append(records, bytes.Repeat([]byte{byte(i)}, 1000+i*997))
+	}
+	return records
+}
+
+func TestSpanningRecords(t *testing.T) {
+	for _, opts := range [][]LogManagerOption{nil, {WithSegmentSize(2)}} {
+		fs := storage.NewMemFS()
+		dm, err := storage.NewDiskManager("lintangdb_chunk", 4096, storage.WithVFS(fs))
+		assert.NoError(t, err)
+		lm, err := NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+
+		records := spanningRecords()
+		lsns := make([]int, len(records))
+		for i, record := range records {
+			lsns[i], err = lm.Append(record)
+			assert.NoError(t, err)
+			if i > 0 {
+				assert.Greater(t, lsns[i], lsns[i-1])
+			}
+		}
+		for i, lsn := range lsns {
+			record, err := lm.ReadAt(lsn)
+			assert.NoError(t, err)
+			assert.Equal(t, records[i], record)
+		}
+
+		// forward & backward iterator menggabungkan chunk jadi record utuh
+		fit, err := lm.GetForwardIterator(0)
+		assert.NoError(t, err)
+		var got [][]byte
+		var gotLSNs []int
+		for record := range fit.IterateLog() {
+			got = append(got, record)
+			gotLSNs = append(gotLSNs, fit.LSN())
+		}
+		assert.NoError(t, fit.GetError())
+		assert.Equal(t, records, got)
+		assert.Equal(t, lsns, gotLSNs)
+
+		lit, err := lm.GetIterator()
+		assert.NoError(t, err)
+		got, gotLSNs = nil, nil
+		for record := range lit.IterateLog() {
+			got = append(got, record)
+			gotLSNs = append(gotLSNs, lit.LSN())
+		}
+		assert.NoError(t, lit.GetError())
+		slices.Reverse(got)
+		slices.Reverse(gotLSNs)
+		assert.Equal(t, records, got)
+		assert.Equal(t, lsns, gotLSNs)
+
+		// lanjut dari tengah record besar: record itu tidak diulang
+		fit, err = lm.GetForwardIterator(lsns[5])
+		assert.NoError(t, err)
+		got = nil
+		for record := range fit.IterateLog() {
+			got = append(got, record)
+		}
+		assert.Equal(t, records[6:], got)
+
+		// reopen: LSN record terakhir = chunk pertama nya
+		lm, err = NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, lsns[len(lsns)-1], lm.LatestLSN())
+		assert.NoError(t, dm.Close())
+	}
+}
+
+func TestSpanningRecordTornTail(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_chunk_torn", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	before, err := lm.Append(createLogMessage("before"))
+	assert.NoError(t, err)
+	assert.NoError(t, lm.Flush(before))
+	_, err = lm.Append(bytes.Repeat([]byte{7}, 10000))
+	assert.NoError(t, err)
+
+	// crash sebelum block chunk terakhir diwrite: chunk tanpa pasangan di-skip
+	lm, err = NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	after, err := lm.Append(createLogMessage("after"))
+	assert.NoError(t, err)
+	lit, err := lm.GetIterator()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"before", "after"}, collectLog(t, lit))
+	fit, err := lm.GetForwardIterator(0)
+	assert.NoError(t, err)
+	var got []string
+	for record := range fit.IterateLog() {
+		got = append(got, storage.NewPageFromByteSlice(record).GetString(0))
+	}
+	assert.NoError(t, fit.GetError())
+	assert.Equal(t, []string{"before", "after"}, got)
+	record, err := lm.ReadAt(after)
+	assert.NoError(t, err)
+	assert.Equal(t, createLogMessage("after"), record)
+}
@@ -0,0 +1,231 @@
+package log
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+ForwardLogIterator. buat iterate log record dari yang terdahulu ke yang terkini, mulai setelah LSN tertentu (redo, replikasi, CDC).
+kalau log dibagi jadi segment, pindah ke segment berikutnya sampai segment terakhir. iterate sampai record terakhir yang sudah ada di disk
+saat block nya dibaca. buat lanjut dari posisi yang disimpan, buat iterator baru dengan LSN record terakhir yang sudah diproses (LSN()).
+*/
+type ForwardLogIterator struct {
+	diskManager storage.BlockManager
+	logFile     string
+	sinceLSN    int // record dengan LSN <= sinceLSN di-skip
+	blockID     storage.BlockID
+	page        *storage.Page
+	positions   []int // posisi record di page yang belum di-yield, urut dari yang terdahulu
+	lsn         int   // LSN record terakhir yang di-yield IterateLog
+	err         error
+}
+
+/*
+NewForwardLogIterator. buat iterator log record dengan LSN > sinceLSN dari log logFile di diskManager (log database / archive hasil ArchiveTo).
+sinceLSN 0 artinya dari record pertama. return ErrLSNOutOfRange kalau record setelah sinceLSN sudah tidak ada (segment nya sudah dihapus).
+*/
+func NewForwardLogIterator(diskManager storage.BlockManager, logFile string, sinceLSN int) (*ForwardLogIterator, error) {
+	files := logFiles(diskManager, logFile)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: log %s not found", ErrLSNOutOfRange, logFile)
+	}
+	blockSize := diskManager.FileBlockSize(files[0])
+	segment, blockNum, _ := splitLSN(sinceLSN, blockSize)
+	file := logFile
+	if _, _, ok := parseSegmentFile(files[0]); ok {
+		file = SegmentFile(logFile, segment)
+	} else if segment != 0 {
+		return nil, fmt.Errorf("%w: %d (log %s is not segmented)", ErrLSNOutOfRange, sinceLSN, logFile)
+	}
+	if start := makeLSN(files[0], 0, blockSize, blockSize); sinceLSN < start || !slices.Contains(files, file) {
+		return nil, fmt.Errorf("%w: %d (log starts at lsn %d)", ErrLSNOutOfRange, sinceLSN, start)
+	}
+	numBlocks, err := diskManager.BlockLength(file)
+	if err != nil {
+		return nil, err
+	}
+	if blockNum >= numBlocks {
+		return nil, fmt.Errorf("%w: %d is after the end of log %s", ErrLSNOutOfRange, sinceLSN, file)
+	}
+
+	fit := &ForwardLogIterator{
+		diskManager: diskManager,
+		logFile:     logFile,
+		sinceLSN:    sinceLSN,
+		page:        storage.NewPage(blockSize),
+	}
+	err = fit.moveToBlock(storage.NewBlockID(file, blockNum))
+	if err != nil {
+		return nil, err
+	}
+	return fit, nil
+}
+
+// moveToBlock. read blockID & kumpulkan posisi record di block dengan LSN > sinceLSN.
+func (fit *ForwardLogIterator) moveToBlock(blockID storage.BlockID) error {
+	err := fit.diskManager.Read(blockID, fit.page)
+	if err != nil {
+		return err
+	}
+	fit.blockID = blockID
+	fit.positions = fit.positions[:0]
+	blockSize := len(fit.page.Contents())
+	// record ditulis dari kanan ke kiri, jadi jalan dari record terakhir di block (boundary) lalu dibalik
+	for off := blockBoundary(fit.page); off+4 <= blockSize; off = chunkEnd(fit.page, off) {
+		if makeLSN(blockID.GetFilename(), blockID.GetBlockNum(), blockSize, off) > fit.sinceLSN {
+			fit.positions = append(fit.positions, off)
+		}
+	}
+	slices.Reverse(fit.positions)
+	return nil
+}
+
+// nextBlock. return block setelah block iterator (block pertama segment berikutnya kalau sudah di block terakhir segment). ok false kalau sudah di akhir log.
+func (fit *ForwardLogIterator) nextBlock() (storage.BlockID, bool, error) {
+	file := fit.blockID.GetFilename()
+	numBlocks, err := fit.diskManager.BlockLength(file)
+	if err != nil {
+		return storage.BlockID{}, false, err
+	}
+	if fit.blockID.GetBlockNum()+1 < numBlocks {
+		return storage.NewBlockID(file, fit.blockID.GetBlockNum()+1), true, nil
+	}
+
+	_, n, ok := parseSegmentFile(file)
+	if !ok {
+		return storage.BlockID{}, false, nil
+	}
+	next := SegmentFile(fit.logFile, n+1)
+	if !slices.Contains(fit.diskManager.Files(), next) {
+		// segment berikutnya belum dibuat, kecuali ada segment setelahnya (segment di tengah log hilang)
+		if nums := segmentNums(fit.diskManager, fit.logFile); len(nums) > 0 && nums[len(nums)-1] > n+1 {
+			return storage.BlockID{}, false, fmt.Errorf("%w: log segment %s is missing", ErrLSNOutOfRange, next)
+		}
+		return storage.BlockID{}, false, nil
+	}
+	numBlocks, err = fit.diskManager.BlockLength(next)
+	if err != nil || numBlocks == 0 {
+		return storage.BlockID{}, false, err
+	}
+	return storage.NewBlockID(next, 0), true, nil
+}
+
+// IterateLog. iterate log record dari yang terdahulu ke yang terkini. jika record di block sudah habis, maka pindah ke block berikutnya.
+// record yang dipotong jadi beberapa chunk (lihat chunk.go) digabung dulu sebelum di-yield.
+func (fit *ForwardLogIterator) IterateLog() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for {
+			for len(fit.positions) > 0 {
+				pos := fit.positions[0]
+				fit.positions = fit.positions[1:]
+				lsn := makeLSN(fit.blockID.GetFilename(), fit.blockID.GetBlockNum(), len(fit.page.Contents()), pos)
+				record, flags := readChunk(fit.page, pos)
+				if flags&chunkContinuation != 0 {
+					continue // lanjutan record yang dimulai sebelum sinceLSN / yang chunk pertamanya tidak ada
+				}
+				if flags&chunkContinued != 0 {
+					if pos != blockBoundary(fit.page) {
+						continue // chunk pertama yang chunk berikutnya tidak ada (record lain di append setelahnya)
+					}
+					var ok bool
+					var err error
+					record, ok, err = fit.readSpanningRecord(record)
+					if err != nil {
+						fit.err = err
+						return
+					}
+					if !ok {
+						continue
+					}
+				}
+				fit.lsn = lsn
+				if !yield(record) {
+					return
+				}
+			}
+
+			block, ok, err := fit.nextBlock()
+			if err != nil {
+				fit.err = err
+				return
+			}
+			if !ok {
+				return
+			}
+			err = fit.moveToBlock(block)
+			if err != nil {
+				fit.err = err
+				return
+			}
+		}
+	}
+}
+
+/*
+readSpanningRecord. gabung chunk pertama first dengan chunk-chunk berikutnya di block-block berikutnya, iterator pindah ke block chunk terakhir.
+ok false kalau chunk berikutnya tidak ada, iterator lanjut dari block terakhir yang dibaca.
+*/
+func (fit *ForwardLogIterator) readSpanningRecord(first []byte) ([]byte, bool, error) {
+	chunks := [][]byte{first}
+	for {
+		block, ok, err := fit.nextBlock()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		err = fit.moveToBlock(block)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(fit.positions) == 0 {
+			return nil, false, nil
+		}
+		chunk, flags := readChunk(fit.page, fit.positions[0]) // chunk berikutnya selalu record pertama di block
+		if flags&chunkContinuation == 0 {
+			return nil, false, nil
+		}
+		fit.positions = fit.positions[1:]
+		chunks = append(chunks, chunk)
+		if flags&chunkContinued == 0 {
+			return slices.Concat(chunks...), true, nil
+		}
+	}
+}
+
+// LSN. return LSN log record terakhir yang di-yield IterateLog (posisi buat lanjut iterate dengan NewForwardLogIterator).
+func (fit *ForwardLogIterator) LSN() int {
+	return fit.lsn
+}
+
+func (fit *ForwardLogIterator) GetError() error {
+	return fit.err
+}
+
+// records. return semua log record sisa iterator beserta LSN nya.
+func (fit *ForwardLogIterator) records() ([]Record, error) {
+	var records []Record
+	for record := range fit.IterateLog() {
+		records = append(records, Record{LSN: fit.LSN(), Data: record})
+	}
+	return records, fit.GetError()
+}
+
+/*
+GetForwardIterator. flush log & return iterator log record dengan LSN > sinceLSN dari yang terdahulu (lihat ForwardLogIterator).
+return ErrLSNOutOfRange kalau sinceLSN di luar log (termasuk record di segment yang sudah dihapus setelah checkpoint).
+*/
+func (lm *LogManager) GetForwardIterator(sinceLSN int) (*ForwardLogIterator, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if sinceLSN < lm.firstLSN() || sinceLSN > lm.latestLSN {
+		return nil, fmt.Errorf("%w: %d (log has lsn %d-%d)", ErrLSNOutOfRange, sinceLSN, lm.firstLSN(), lm.latestLSN)
+	}
+	err := lm.flush()
+	if err != nil {
+		return nil, err
+	}
+	return NewForwardLogIterator(lm.diskManager, lm.logFile, sinceLSN)
+}
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// collectForward. return isi log record dari forward iterator sampai stop record (semua kalau stop < 0) & LSN record terakhir.
+func collectForward(t *testing.T, fit *ForwardLogIterator, stop int) ([]string, int) {
+	var records []string
+	lsn := 0
+	for record := range fit.IterateLog() {
+		assert.Greater(t, fit.LSN(), lsn)
+		lsn = fit.LSN()
+		records = append(records, storage.NewPageFromByteSlice(record).GetString(0))
+		if len(records) == stop {
+			break
+		}
+	}
+	assert.NoError(t, fit.GetError())
+	return records, lsn
+}
+
+func TestForwardLogIterator(t *testing.T) {
+	for _, opts := range [][]LogManagerOption{nil, {WithSegmentSize(2)}} {
+		fs := storage.NewMemFS()
+		dm, err := storage.NewDiskManager("lintangdb_forward", 4096, storage.WithVFS(fs))
+		assert.NoError(t, err)
+		lm, err := NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+		createLogRecordAndAppendToLogFile(t, lm, 0, 1000)
+
+		fit, err := lm.GetForwardIterator(0)
+		assert.NoError(t, err)
+		records, _ := collectForward(t, fit, -1)
+		assert.Equal(t, logMessages(0, 1000), records)
+
+		// simpan posisi, lalu lanjut dari posisi itu setelah log dibuka ulang
+		fit, err = lm.GetForwardIterator(0)
+		assert.NoError(t, err)
+		records, saved := collectForward(t, fit, 400)
+		assert.Equal(t, logMessages(0, 400), records)
+		lm, err = NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+		createLogRecordAndAppendToLogFile(t, lm, 1000, 1100)
+		fit, err = lm.GetForwardIterator(saved)
+		assert.NoError(t, err)
+		records, lsn := collectForward(t, fit, -1)
+		assert.Equal(t, logMessages(400, 1100), records)
+		assert.Equal(t, lm.LatestLSN(), lsn)
+
+		// sudah di record terakhir: tidak ada record lagi
+		fit, err = lm.GetForwardIterator(lm.LatestLSN())
+		assert.NoError(t, err)
+		records, _ = collectForward(t, fit, -1)
+		assert.Empty(t, records)
+
+		_, err = lm.GetForwardIterator(lm.LatestLSN() + 1)
+		assert.ErrorIs(t, err, ErrLSNOutOfRange)
+		assert.NoError(t, dm.Close())
+	}
+}
+
+func TestForwardLogIteratorSegments(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_forward_segment", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithSegmentSize(2))
+	assert.NoError(t, err)
+	createLogRecordAndAppendToLogFile(t, lm, 0, 1000)
+	segments := lm.Segments()
+	checkpoint, err := lm.Checkpoint()
+	assert.NoError(t, err)
+	for i := 1000; i < 1010; i++ {
+		_, err = lm.Append(createLogMessage(fmt.Sprintf("lintang %d", i)))
+		assert.NoError(t, err)
+	}
+
+	// record sebelum segment checkpoint sudah dihapus
+	_, err = lm.GetForwardIterator(0)
+	assert.ErrorIs(t, err, ErrLSNOutOfRange)
+	fit, err := lm.GetForwardIterator(checkpoint)
+	assert.NoError(t, err)
+	records, _ := collectForward(t, fit, -1)
+	assert.Equal(t, logMessages(1000, 1010), records)
+
+	// segment di tengah log hilang
+	createLogRecordAndAppendToLogFile(t, lm, 1010, 2000)
+	assert.Greater(t, len(lm.Segments()), 2)
+	assert.NoError(t, dm.DeleteFile(lm.Segments()[1]))
+	fit, err = NewForwardLogIterator(dm, "lintangdb.log", checkpoint)
+	assert.NoError(t, err)
+	for range fit.IterateLog() {
+	}
+	assert.ErrorIs(t, fit.GetError(), ErrLSNOutOfRange)
+	assert.NotContains(t, dm.Files(), segments[0])
+}
@@ -2,22 +2,24 @@ package log
 
 import (
 	"iter"
+	"slices"
 
 	"github.com/lintang-b-s/go-simpledb/pkg/storage"
 )
 
-// LogIterator. buat iterate log record yang udah ditulis di file. iteratenya darii yang terakhir ditulis ke yang terdahulu.
+// LogIterator. buat iterate log record yang udah ditulis di file. iteratenya darii yang terakhir ditulis ke yang terdahulu. kalau log dibagi jadi segment, iterate sampai segment paling lama yang masih ada.
 type LogIterator struct {
-	diskManager DiskManager
+	diskManager storage.BlockManager
 	blockID     storage.BlockID
 	page        *storage.Page
 	currentPos  int
+	recordPos   int // posisi record terakhir yang di-yield IterateLog di page
 	blockSize   int
 	err         error
 }
 
-func NewLogIterator(diskManager DiskManager, blockID storage.BlockID) (*LogIterator, error) {
-	page := storage.NewPageFromByteSlice(make([]byte, diskManager.BlockSize()))
+func NewLogIterator(diskManager storage.BlockManager, blockID storage.BlockID) (*LogIterator, error) {
+	page := storage.NewPage(diskManager.FileBlockSize(blockID.GetFilename()))
 	err := diskManager.Read(blockID, page) // read blockID dari file
 	if err != nil {
 		return &LogIterator{}, err
@@ -28,7 +30,7 @@ func NewLogIterator(diskManager DiskManager, blockID storage.BlockID) (*LogItera
 		blockID:     blockID,
 		page:        page,
 		currentPos:  0,
-		blockSize:   page.GetInt(0),
+		blockSize:   blockBoundary(page),
 		err:         nil,
 	}
 	lit.moveToBlock(blockID) // move iterator ke blockID
@@ -41,7 +43,7 @@ func (lit *LogIterator) moveToBlock(blockID storage.BlockID) error {
 	if err != nil {
 		return err
 	}
-	lit.blockSize = lit.page.GetInt(0)
+	lit.blockSize = blockBoundary(lit.page)
 	lit.currentPos = lit.blockSize
 	return nil
 }
@@ -50,27 +52,38 @@ func (lit *LogIterator) moveToBlock(blockID storage.BlockID) error {
 IterateLog. iterate next log record di dalam block dari yang terkini ke yang terdahulu. jika sudah habis, maka pindah ke block sebelumnya.
 
 	iterate log record perblocknya dari kiri ke kanan shg urutan iterasinya dari log yang terakhir ditambahkan ke yang terdahulu.
+	record yang dipotong jadi beberapa chunk (lihat chunk.go) digabung dulu sebelum di-yield.
 */
 func (lit *LogIterator) IterateLog() iter.Seq[[]byte] {
 	return func(yield func([]byte) bool) {
 		for lit.blockID.GetBlockNum() >= 0 {
 
-			if lit.currentPos >= lit.diskManager.BlockSize() {
-				// jika sudah habis, maka pindah ke block sebelumnya.
-				block := storage.NewBlockID(lit.blockID.GetFilename(), lit.blockID.GetBlockNum()-1)
-				if block.GetBlockNum() < 0 {
-					break
-				}
-				lit.blockID = block
-				err := lit.moveToBlock(block) // buat  iterator.page  read block baru
-				if err != nil {
-					lit.err = err
+			if lit.currentPos >= len(lit.page.Contents()) {
+				// jika sudah habis, maka pindah ke block sebelumnya (block kosong, mis. block torn yang diganti pas NewLogManager, dilewati).
+				if !lit.previousBlock() {
 					break
 				}
+				continue
 			}
 
-			record := lit.page.GetBytes(lit.currentPos) // get satu logRecord dari currentPos
-			lit.currentPos += 4 + len(record)           // increment currentPos + 4 ( karena ada length di awal record)
+			lit.recordPos = lit.currentPos
+			record, flags := readChunk(lit.page, lit.currentPos) // get satu logRecord dari currentPos
+			lit.currentPos = chunkEnd(lit.page, lit.currentPos)  // increment currentPos + 4 ( karena ada length di awal record)
+
+			if flags&chunkContinued != 0 {
+				continue // chunk pertama / tengah yang chunk terakhirnya tidak ada
+			}
+			if flags&chunkContinuation != 0 {
+				// chunk terakhir record yang dimulai di block sebelumnya
+				var ok bool
+				record, ok = lit.readSpanningRecord(record)
+				if !ok {
+					if lit.err != nil {
+						break
+					}
+					continue
+				}
+			}
 
 			if !yield(record) {
 				return
@@ -80,6 +93,74 @@ func (lit *LogIterator) IterateLog() iter.Seq[[]byte] {
 	}
 }
 
+// previousBlock. move iterator ke block sebelumnya (block terakhir segment sebelumnya kalau sudah di block pertama segment). return false kalau sudah di awal log / error.
+func (lit *LogIterator) previousBlock() bool {
+	block := storage.NewBlockID(lit.blockID.GetFilename(), lit.blockID.GetBlockNum()-1)
+	if block.GetBlockNum() < 0 {
+		// block pertama segment, pindah ke block terakhir segment sebelumnya (kalau masih ada)
+		var ok bool
+		block, ok, lit.err = lit.previousSegmentBlock()
+		if !ok {
+			return false
+		}
+	}
+	lit.blockID = block
+	err := lit.moveToBlock(block) // buat  iterator.page  read block baru
+	if err != nil {
+		lit.err = err
+		return false
+	}
+	return true
+}
+
+/*
+readSpanningRecord. gabung chunk terakhir tail dengan chunk-chunk sebelumnya di block-block sebelumnya, iterator pindah ke block chunk pertama.
+ok false kalau chunk sebelumnya tidak ada, iterator lanjut dari block terakhir yang dibaca.
+*/
+func (lit *LogIterator) readSpanningRecord(tail []byte) ([]byte, bool) {
+	chunks := [][]byte{tail}
+	for lit.previousBlock() {
+		pos := lit.currentPos // chunk sebelumnya selalu record terakhir di block
+		if pos+4 > len(lit.page.Contents()) {
+			return nil, false
+		}
+		chunk, flags := readChunk(lit.page, pos)
+		if flags&chunkContinued == 0 {
+			return nil, false
+		}
+		lit.recordPos = pos
+		lit.currentPos = chunkEnd(lit.page, pos)
+		chunks = append(chunks, chunk)
+		if flags&chunkContinuation == 0 {
+			slices.Reverse(chunks)
+			return slices.Concat(chunks...), true
+		}
+	}
+	return nil, false
+}
+
+// previousSegmentBlock. return block terakhir segment sebelum segment block iterator. ok false kalau block iterator bukan di segment log atau segment sebelumnya sudah tidak ada.
+func (lit *LogIterator) previousSegmentBlock() (storage.BlockID, bool, error) {
+	logFile, n, ok := parseSegmentFile(lit.blockID.GetFilename())
+	if !ok || n == 0 {
+		return storage.BlockID{}, false, nil
+	}
+	prev := SegmentFile(logFile, n-1)
+	if !slices.Contains(lit.diskManager.Files(), prev) {
+		return storage.BlockID{}, false, nil // sudah dihapus / dipindah ke archive setelah checkpoint
+	}
+	numBlocks, err := lit.diskManager.BlockLength(prev)
+	if err != nil || numBlocks == 0 {
+		return storage.BlockID{}, false, err
+	}
+	return storage.NewBlockID(prev, numBlocks-1), true, nil
+}
+
+// LSN. return LSN log record terakhir yang di-yield IterateLog.
+func (lit *LogIterator) LSN() int {
+	return makeLSN(lit.blockID.GetFilename(), lit.blockID.GetBlockNum(), len(lit.page.Contents()), lit.recordPos)
+}
+
 func (lit *LogIterator) GetError() error {
 	return lit.err
 }
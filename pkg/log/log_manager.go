@@ -1,101 +1,273 @@
 package log
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/lintang-b-s/go-simpledb/pkg/storage"
 )
 
-type DiskManager interface {
-	Read(blockID storage.BlockID, page *storage.Page) error
-	Write(blockID storage.BlockID, page *storage.Page) error
-	Append(fileName string) (storage.BlockID, error)
-	BlockLength(fileName string) (int, error)
-	BlockSize() int
-	GetDBDir() string
-}
+// ErrLSNOutOfRange. dikembalikan kalau LSN tidak ada di log.
+var ErrLSNOutOfRange = errors.New("lsn out of range")
+
+// maxPendingLogBlocks. jumlah maksimal block log penuh yang ditahan di memori sebelum diwrite ke disk tanpa menunggu Flush.
+const maxPendingLogBlocks = 16
 
 // buat write & read log records ke log file.
 type LogManager struct {
-	diskManager    DiskManager
+	diskManager    storage.BlockManager
 	logFile        string
 	logPage        *storage.Page
-	currentBlockID storage.BlockID // current block id dari lsn
-	latestLSN      int             // log sequence number : log record identifier . LSn terakhir di memori
-	lastSavedLSN   int             // LSN terakhir yang sudah diwrite ke disk
+	currentBlockID storage.BlockID      // current block id dari lsn
+	latestLSN      int                  // log sequence number : log record identifier (posisi record di log, lihat lsn.go) . LSn terakhir di memori
+	pending        []storage.BlockWrite // block log penuh yang belum diwrite ke disk, diwrite bareng flush berikutnya
+	lastSavedLSN   int                  // LSN terakhir yang sudah diwrite & di fsync ke disk
+
+	mu       sync.Mutex
+	flushed  *sync.Cond    // broadcast setiap group commit selesai
+	flushing bool          // true kalau ada goroutine (leader) yang sedang group commit
+	waiters  int           // jumlah goroutine yang menunggu group commit yang sedang berjalan
+	full     chan struct{} // signal ke leader kalau jumlah waiters sudah mencapai groupCommitSize
+	numSyncs int           // jumlah fsync log file
+
+	groupCommitDelay time.Duration // berapa lama leader menunggu commit lain sebelum fsync
+	groupCommitSize  int           // leader langsung fsync kalau jumlah commit yang menunggu sudah mencapai groupCommitSize
+
+	pageLogging bool // log page image setiap write block (lihat WithPageLogging)
+
+	segmentSize    int                  // jumlah block per segment log, 0 kalau log tidak dibagi jadi segment (lihat WithSegmentSize)
+	archive        storage.BlockManager // tujuan segment yang tidak diperlukan lagi setelah checkpoint, nil kalau segment dihapus
+	firstSegment   int                  // nomor segment log paling lama yang masih ada
+	currentSegment int                  // nomor segment log tempat record di append
+	checkpointMu   sync.Mutex           // serialize Checkpoint
 }
 
-func NewLogManager(diskManager DiskManager, logFile string) (*LogManager, error) {
+// lsnTracker. BlockManager yang bisa mencatat block LSN (storage.DiskManager).
+type lsnTracker interface {
+	SetLSNSource(src storage.LSNSource)
+}
 
-	b := make([]byte, diskManager.BlockSize())
-	logPage := storage.NewPageFromByteSlice(b)       // create new page for log file
-	logSize, err := diskManager.BlockLength(logFile) // get jumlah block pada log file
+// pageLoggingManager. BlockManager yang bisa log page image setiap write block (storage.DiskManager).
+type pageLoggingManager interface {
+	SetPageLogger(logger storage.PageLogger)
+}
+
+// LogManagerOption. option buat konfigurasi LogManager pas NewLogManager.
+type LogManagerOption func(*LogManager)
 
+// WithGroupCommitDelay. set berapa lama Flush menunggu commit lain biar bisa di fsync bareng. delay lebih besar -> throughput lebih tinggi tapi latency commit naik.
+func WithGroupCommitDelay(delay time.Duration) LogManagerOption {
+	return func(lm *LogManager) {
+		lm.groupCommitDelay = delay
+	}
+}
+
+/*
+WithPageLogging. log page image setiap block yang diwrite ke disk (archive mode, buat point-in-time recovery dari log yang diarsip).
+log jadi jauh lebih besar karena isi setiap block yang diwrite ikut di log.
+*/
+func WithPageLogging(enabled bool) LogManagerOption {
+	return func(lm *LogManager) {
+		lm.pageLogging = enabled
+	}
+}
+
+// WithGroupCommitSize. set jumlah maksimal commit dalam satu group commit. kalau sudah tercapai, fsync tanpa menunggu groupCommitDelay.
+func WithGroupCommitSize(size int) LogManagerOption {
+	return func(lm *LogManager) {
+		lm.groupCommitSize = size
+	}
+}
+
+func NewLogManager(diskManager storage.BlockManager, logFile string, opts ...LogManagerOption) (*LogManager, error) {
+	lm := &LogManager{
+		diskManager:     diskManager,
+		logFile:         logFile,
+		currentBlockID:  storage.BlockID{},
+		latestLSN:       0,
+		lastSavedLSN:    0,
+		full:            make(chan struct{}, 1),
+		groupCommitSize: 64,
+	}
+	lm.flushed = sync.NewCond(&lm.mu)
+	for _, opt := range opts {
+		opt(lm)
+	}
+
+	file, err := lm.openSegments() // file tempat record di append: log file, atau segment log terakhir
 	if err != nil {
 		return &LogManager{}, err
 	}
+	logPage := storage.NewPage(diskManager.FileBlockSize(file)) // create new page for log file
+	logSize, err := diskManager.BlockLength(file)               // get jumlah block pada log file
+	if err != nil {
+		return &LogManager{}, err
+	}
+	lm.logPage = logPage
+	blockSize := len(logPage.Contents())
 
-	lm := &LogManager{
-		diskManager:    diskManager,
-		logFile:        logFile,
-		logPage:        logPage,
-		currentBlockID: storage.BlockID{},
-		latestLSN:      0,
-		lastSavedLSN:   0,
+	if tracker, ok := diskManager.(lsnTracker); ok {
+		tracker.SetLSNSource(lm) // write block berikutnya mencatat block LSN (buat incremental backup)
+	}
+	if pl, ok := diskManager.(pageLoggingManager); ok && lm.pageLogging {
+		pl.SetPageLogger(lm)
 	}
 
 	if logSize == 0 {
 		// jika jumlah block==0 (log file kosong), maka tambahkan block baru
-		lm.currentBlockID, err = lm.appendNewBlock()
+		lm.currentBlockID, err = lm.appendNewBlock(file)
 		if err != nil {
 			return &LogManager{}, err
 		}
 	} else {
 		// else read dari disk , read block terakhir
-		lm.currentBlockID = storage.NewBlockID(logFile, logSize-1)
+		lm.currentBlockID = storage.NewBlockID(file, logSize-1)
 		err = diskManager.Read(lm.currentBlockID, logPage)
+		if isTornBlock(err) {
+			err = lm.skipTornBlock()
+		}
 		if err != nil {
 			return &LogManager{}, err
 		}
+		logPage.PutInt(0, blockBoundary(logPage))
+	}
+	// LSN lanjut dari posisi record terakhir di log
+	lm.latestLSN = makeLSN(lm.currentBlockID.GetFilename(), lm.currentBlockID.GetBlockNum(), blockSize, logPage.GetInt(0))
+	if boundary := logPage.GetInt(0); boundary+4 <= blockSize && logPage.GetInt(boundary)&^chunkLengthMask != 0 {
+		// record terakhir dipotong jadi beberapa chunk: LSN nya posisi chunk pertama
+		lit, err := NewLogIterator(diskManager, lm.currentBlockID)
+		if err != nil {
+			return &LogManager{}, err
+		}
+		for range lit.IterateLog() {
+			lm.latestLSN = lit.LSN()
+			break
+		}
+		if lit.GetError() != nil {
+			return &LogManager{}, lit.GetError()
+		}
 	}
+	lm.lastSavedLSN = lm.latestLSN
 
 	return lm, nil
 }
 
-// fluflush2sh. flush logPage  ke disk, write offset == currentBlockID*blockSize
+/*
+Flush. pastikan log record dengan LSN <= lsn sudah persist di disk (group commit).
+kalau tidak ada flush yang sedang berjalan, goroutine ini jadi leader: tunggu groupCommitDelay (atau sampai groupCommitSize commit menunggu)
+biar commit lain bisa ikut, lalu write logPage & fsync sekali buat semua commit. goroutine lain yang Flush selama itu menunggu hasil leader.
+*/
 func (lm *LogManager) Flush(lsn int) error {
-	if lsn > lm.lastSavedLSN {
-		err := lm.Flush2()
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for lsn > lm.lastSavedLSN {
+		if lm.flushing {
+			// ikut group commit yang sedang berjalan
+			lm.waiters++
+			if lm.waiters+1 >= lm.groupCommitSize {
+				select {
+				case lm.full <- struct{}{}:
+				default:
+				}
+			}
+			lm.flushed.Wait()
+			lm.waiters--
+			continue
+		}
+
+		// jadi leader group commit. token full dari follower yang datang setelah leader sebelumnya timeout dibuang,
+		// biar leader ini tetap menunggu groupCommitDelay (follower cuma kirim token selama flushing, sambil hold lm.mu)
+		select {
+		case <-lm.full:
+		default:
+		}
+		lm.flushing = true
+		if lm.groupCommitDelay > 0 {
+			lm.mu.Unlock() // biar goroutine lain bisa append & ikut group commit
+			timer := time.NewTimer(lm.groupCommitDelay)
+			select {
+			case <-timer.C:
+			case <-lm.full:
+				timer.Stop()
+			}
+			lm.mu.Lock()
+		}
+		err := lm.flush()
+		lm.flushing = false
+		lm.flushed.Broadcast()
 		return err
 	}
 	return nil
 }
 
-// fluflush2sh. flush logPage  ke disk, write offset pada file == currentBlockID*blockSize
+// Flush2. flush logPage ke disk & fsync log file, write offset pada file == currentBlockID*blockSize
 func (lm *LogManager) Flush2() error {
-	err := lm.diskManager.Write(lm.currentBlockID, lm.logPage)
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.flush()
+}
+
+/*
+flush. write block log yang pending & logPage ke disk lalu fsync. caller harus hold lm.mu.
+block log ditulis lewat WriteBlocks (doublewrite), karena block yang sudah berisi record persist ditulis ulang di tempat:
+kalau crash pas write, block (& checksum nya di file terpisah) di repair pas startup, record lama tidak hilang.
+*/
+func (lm *LogManager) flush() error {
+	err := lm.diskManager.WriteBlocks(append(lm.pending, storage.BlockWrite{BlockID: lm.currentBlockID, Page: lm.logPage}))
 	if err != nil {
 		return err
 	}
+	lm.pending = nil
+	lm.numSyncs++
 	lm.lastSavedLSN = lm.latestLSN // update lastSavedLSN
 	return nil
 }
 
-// appendNewBlock. menambahkan block baru kosong ke logfile. & write logPage ke disk di offset block yang baru.
-func (lm *LogManager) appendNewBlock() (storage.BlockID, error) {
-	block, err := lm.diskManager.Append(lm.logFile) // append block baru ke log file
+// appendNewBlock. menambahkan block baru kosong ke file (log file / segment log) & reset logPage jadi block kosong. block baru diwrite pas flush.
+func (lm *LogManager) appendNewBlock(file string) (storage.BlockID, error) {
+	block, err := lm.diskManager.Append(file) // append block baru ke log file
 	if err != nil {
 		return storage.BlockID{}, err
 	}
 
-	lm.logPage.PutInt(0, lm.diskManager.BlockSize()) // set blockSize pada logPage
-	err = lm.diskManager.Write(block, lm.logPage)    // write logPage ke disk
+	lm.logPage.PutInt(0, len(lm.logPage.Contents())) // set blockSize pada logPage
+	return block, nil
+}
+
+// blockBoundary. return offset record terakhir di block log (block size kalau block kosong).
+// block hasil Append yang belum pernah diwrite (semua byte 0) dianggap kosong.
+func blockBoundary(page *storage.Page) int {
+	if boundary := page.GetInt(0); boundary != 0 {
+		return boundary
+	}
+	return len(page.Contents())
+}
+
+// isTornBlock. return true kalau err dari read block log yang isinya tidak valid (mis. crash pas block diwrite tanpa doublewrite):
+// checksum tidak cocok, atau nonce/tag di file .enc tidak cocok dengan ciphertext block nya.
+func isTornBlock(err error) bool {
+	return errors.Is(err, storage.ErrChecksumMismatch) || errors.Is(err, storage.ErrDecryptionFailed)
+}
+
+// skipTornBlock. block terakhir log torn: dianggap akhir log. block itu diganti block kosong & record berikutnya di block baru, jadi LSN tetap naik.
+func (lm *LogManager) skipTornBlock() error {
+	clear(lm.logPage.Contents())
+	lm.logPage.PutInt(0, len(lm.logPage.Contents()))
+	err := lm.moveToNewBlock()
 	if err != nil {
-		return storage.BlockID{}, err
+		return err
 	}
-	return block, nil
+	return lm.flush()
 }
 
 func (lm *LogManager) GetIterator() (*LogIterator, error) {
-	lm.Flush2()
+	err := lm.Flush2()
+	if err != nil {
+		return nil, err
+	}
 	return NewLogIterator(lm.diskManager, lm.currentBlockID)
 }
 
@@ -106,24 +278,171 @@ pada awal buffer terdapat lokasi record yang ditulis paling terakhir.
 iterate log record perblocknya dari kiri ke kanan shg urutan iterasinya dari log yang terakhir ditambahkan ke yang terdahulu.
 */
 func (lm *LogManager) append(logRecord []byte) (int, error) {
-	logBlockSize := lm.logPage.GetInt(0) // get blockSize dari logPage (tergantung MAX_PAGE_SIZE )
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.appendLocked(logRecord)
+}
+
+// appendLocked. lihat append. record yang tidak muat di satu block dipotong jadi beberapa chunk (lihat chunk.go). caller harus hold lm.mu.
+func (lm *LogManager) appendLocked(logRecord []byte) (int, error) {
+	if len(logRecord) > chunkLengthMask {
+		return 0, fmt.Errorf("log record of %d bytes is too large", len(logRecord))
+	}
+	logBlockSize := lm.logPage.GetInt(0) // get blockSize dari logPage (sisa space block log)
 	recordSize := len(logRecord)         // get size dari logRecord
 	bytesNeeded := recordSize + 4        // bytesNeeded = recordSize + 4 (4 bytes untuk menyimpan recordSize). bytesneeded untuk simpan logRecord
-	var err error
-	if bytesNeeded+4 > logBlockSize {
-		// jika recordSize > logBlockSize,  flush block sebelumnya ke disk &  create new block.
-		lm.Flush2()
-		lm.currentBlockID, err = lm.appendNewBlock() // update currentBlockID ke next blockID
+	if bytesNeeded+4 > logBlockSize && (bytesNeeded+4 <= len(lm.logPage.Contents()) || logBlockSize-8 <= 0) {
+		// jika record tidak muat tapi muat di block baru (atau block sudah penuh), write block sebelumnya ke disk & create new block.
+		err := lm.moveToNewBlock()
 		if err != nil {
 			return 0, err
 		}
 		logBlockSize = lm.logPage.GetInt(0)
 	}
 
-	recordPosition := logBlockSize - bytesNeeded // posisi record yang ditulis paling akhir
+	if bytesNeeded+4 <= logBlockSize {
+		recordPosition := logBlockSize - bytesNeeded // posisi record yang ditulis paling akhir
+
+		lm.logPage.PutBytes(recordPosition, logRecord) // write logRecord ke logPage pada offset recordPosition
+		lm.logPage.PutInt(0, recordPosition)           // update sisa blockSize pada logPage
+		// update latestLSN: LSN = posisi record di log
+		lm.latestLSN = makeLSN(lm.currentBlockID.GetFilename(), lm.currentBlockID.GetBlockNum(), len(lm.logPage.Contents()), recordPosition)
+		return lm.latestLSN, nil
+	}
 
-	lm.logPage.PutBytes(recordPosition, logRecord) // write logRecord ke logPage pada offset recordPosition
-	lm.logPage.PutInt(0, recordPosition)           // update sisa blockSize pada logPage
-	lm.latestLSN++                                 // update latestLSN
+	// record lebih besar dari block: chunk pertama di sisa space block sekarang, chunk berikutnya masing-masing di block baru
+	lsn := 0
+	flags := 0
+	for rest := logRecord; len(rest) > 0; flags = chunkContinuation {
+		if lm.logPage.GetInt(0)-8 <= 0 {
+			err := lm.moveToNewBlock()
+			if err != nil {
+				return 0, err
+			}
+		}
+		boundary := lm.logPage.GetInt(0)
+		n := min(len(rest), boundary-8)
+		chunkFlags := flags
+		if n < len(rest) {
+			chunkFlags |= chunkContinued
+		}
+		pos := boundary - 4 - n
+		lm.logPage.PutBytes(pos, rest[:n])
+		lm.logPage.PutInt(pos, n|chunkFlags)
+		lm.logPage.PutInt(0, pos)
+		if lsn == 0 {
+			lsn = makeLSN(lm.currentBlockID.GetFilename(), lm.currentBlockID.GetBlockNum(), len(lm.logPage.Contents()), pos)
+		}
+		rest = rest[n:]
+	}
+	lm.latestLSN = lsn
 	return lm.latestLSN, nil
 }
+
+// moveToNewBlock. simpan block log sekarang ke pending (diwrite pas Flush berikutnya) & pindah ke block baru (di segment berikutnya kalau segment penuh). caller harus hold lm.mu.
+func (lm *LogManager) moveToNewBlock() error {
+	full := storage.NewPage(len(lm.logPage.Contents()))
+	copy(full.Contents(), lm.logPage.Contents())
+	lm.pending = append(lm.pending, storage.BlockWrite{BlockID: lm.currentBlockID, Page: full})
+	if len(lm.pending) >= maxPendingLogBlocks {
+		err := lm.diskManager.WriteBlocks(lm.pending)
+		if err != nil {
+			return err
+		}
+		lm.pending = nil
+	}
+
+	file := lm.currentBlockID.GetFilename()
+	var err error
+	if lm.segmentSize > 0 && lm.currentBlockID.GetBlockNum()+1 >= lm.segmentSize {
+		file, err = lm.nextSegment() // segment penuh, block baru di segment berikutnya
+		if err != nil {
+			return err
+		}
+	}
+	lm.currentBlockID, err = lm.appendNewBlock(file) // update currentBlockID ke next blockID
+	return err
+}
+
+// Append. append log record ke log buffer & return LSN nya. record belum tentu persist sampai Flush(lsn).
+func (lm *LogManager) Append(logRecord []byte) (int, error) {
+	return lm.append(logRecord)
+}
+
+// Commit. append log record commit transaksi txNum dengan waktu sekarang & tunggu sampai persist (group commit). return LSN record commit.
+func (lm *LogManager) Commit(txNum int) (int, error) {
+	lsn, err := lm.append(Commit{TxNum: txNum, Time: time.Now()}.Encode())
+	if err != nil {
+		return 0, err
+	}
+	return lsn, lm.Flush(lsn)
+}
+
+// LatestLSN. return LSN log record terakhir yang di append.
+func (lm *LogManager) LatestLSN() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.latestLSN
+}
+
+// BlockLSN. return LSN terakhir sebagai block LSN block yang diwrite (implementasi storage.LSNSource). block log file sendiri tidak dicatat.
+func (lm *LogManager) BlockLSN(blockID storage.BlockID) int {
+	if lm.IsLogFile(blockID.GetFilename()) {
+		return -1
+	}
+	return lm.LatestLSN()
+}
+
+// LogFile. return nama log file (nama dasar segment log kalau log dibagi jadi segment).
+func (lm *LogManager) LogFile() string {
+	return lm.logFile
+}
+
+// IsLogFile. return true kalau filename log file atau segment log LogManager ini.
+func (lm *LogManager) IsLogFile(filename string) bool {
+	base, _, ok := parseSegmentFile(filename)
+	return filename == lm.logFile || ok && base == lm.logFile
+}
+
+/*
+LogPage. append isi block yang mau diwrite ke disk sebagai log record page image (implementasi storage.PageLogger, lihat DiskManager.SetPageLogger).
+page image yang lebih besar dari block log dipotong jadi beberapa chunk di block log berikutnya. write block log file sendiri tidak dicatat.
+*/
+func (lm *LogManager) LogPage(blockID storage.BlockID, contents []byte) error {
+	if lm.IsLogFile(blockID.GetFilename()) {
+		return nil
+	}
+	img := PageImage{BlockID: blockID, Data: contents}
+	_, err := lm.append(img.Encode())
+	return err
+}
+
+// Record. log record beserta LSN nya.
+type Record struct {
+	LSN  int
+	Data []byte
+}
+
+// RecordsSince. flush log & return log record dengan LSN > lsn (urut dari yang terdahulu) beserta LSN log record terakhir.
+// return ErrLSNOutOfRange kalau record setelah lsn sudah tidak ada di log (segment nya sudah dihapus setelah checkpoint).
+func (lm *LogManager) RecordsSince(lsn int) ([]Record, int, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn < lm.firstLSN() || lsn > lm.latestLSN {
+		return nil, 0, fmt.Errorf("%w: %d (log has lsn %d-%d)", ErrLSNOutOfRange, lsn, lm.firstLSN(), lm.latestLSN)
+	}
+	err := lm.flush()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fit, err := NewForwardLogIterator(lm.diskManager, lm.logFile, lsn)
+	if err != nil {
+		return nil, 0, err
+	}
+	records, err := fit.records()
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, lm.latestLSN, nil
+}
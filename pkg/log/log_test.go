@@ -3,7 +3,10 @@ package log
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lintang-b-s/go-simpledb/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -18,14 +21,19 @@ func createLogMessage(name string) []byte {
 }
 
 func createLogRecordAndAppendToLogFile(t *testing.T, lm *LogManager, start, end int) {
-
+	prevLSN := lm.LatestLSN()
 	for i := start; i < end; i++ {
 		newLogRecord := createLogMessage(fmt.Sprintf("lintang %d", i))
 		lsn, err := lm.append(newLogRecord)
 		if err != nil {
 			t.Errorf("Error appending log record: %s  ke-%d", err, i)
 		}
-		assert.Equal(t, i+1, lsn)
+		// LSN selalu naik & menunjuk ke record nya
+		assert.Greater(t, lsn, prevLSN)
+		prevLSN = lsn
+		record, err := lm.ReadAt(lsn)
+		assert.NoError(t, err)
+		assert.Equal(t, newLogRecord, record)
 	}
 }
 func printLogRecord(t *testing.T, lm *LogManager, maxLogIDx int) {
@@ -48,10 +56,14 @@ func printLogRecord(t *testing.T, lm *LogManager, maxLogIDx int) {
 }
 
 func TestLogManager(t *testing.T) {
-	dm := storage.NewDiskManager("lintangdb", 4096)
-	_, err := os.Stat("lintangdb")
+	dm, err := storage.NewDiskManager("lintangdb", 4096)
+	if err != nil {
+		t.Fatalf("Error creating disk manager: %s", err)
+	}
+	_, err = os.Stat("lintangdb")
 	if err == nil {
-		os.Remove("lintangdb.log")
+		os.Remove("lintangdb/lintangdb.log")
+		os.Remove("lintangdb/lintangdb.log.crc")
 	}
 	lm, err := NewLogManager(dm, "lintangdb.log")
 	if err != nil {
@@ -65,3 +77,165 @@ func TestLogManager(t *testing.T) {
 		printLogRecord(t, lm, 10000)
 	})
 }
+
+func TestGroupCommit(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb", 4096, storage.WithVFS(storage.NewMemFS()))
+	if err != nil {
+		t.Fatalf("Error creating disk manager: %s", err)
+	}
+	lm, err := NewLogManager(dm, "lintangdb.log", WithGroupCommitDelay(20*time.Millisecond), WithGroupCommitSize(100))
+	if err != nil {
+		t.Fatalf("Error creating log manager: %s", err)
+	}
+
+	const numCommits = 50
+	var wg sync.WaitGroup
+	for i := 0; i < numCommits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lsn, err := lm.append(createLogMessage(fmt.Sprintf("commit %d", i)))
+			assert.NoError(t, err)
+			assert.NoError(t, lm.Flush(lsn))
+
+			lm.mu.Lock()
+			assert.GreaterOrEqual(t, lm.lastSavedLSN, lsn)
+			lm.mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	// commit yang concurrent di fsync bareng
+	assert.Less(t, lm.numSyncs, numCommits)
+	assert.Equal(t, lm.latestLSN, lm.lastSavedLSN)
+}
+
+func TestGroupCommitStaleFullToken(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_group", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithGroupCommitDelay(50*time.Millisecond), WithGroupCommitSize(2))
+	assert.NoError(t, err)
+
+	// token dari follower yang masuk setelah leader sebelumnya timeout tidak boleh bikin leader berikutnya skip groupCommitDelay
+	lm.full <- struct{}{}
+	lsn, err := lm.Append(createLogMessage("lintang"))
+	assert.NoError(t, err)
+	start := time.Now()
+	assert.NoError(t, lm.Flush(lsn))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestTornLastLogBlock(t *testing.T) {
+	provider, err := storage.NewFileKeyProvider(filepath.Join(t.TempDir(), "master.key"))
+	assert.NoError(t, err)
+	for _, encrypted := range []bool{false, true} {
+		opts := []storage.DiskManagerOption{storage.WithDoubleWrite(false)}
+		if encrypted {
+			opts = append(opts, storage.WithEncryption(provider))
+		}
+		fs := storage.NewMemFS()
+		dm, err := storage.NewDiskManager("lintangdb_torn", 4096, append(opts, storage.WithVFS(fs))...)
+		assert.NoError(t, err)
+		testTornLastLogBlock(t, fs, dm, encrypted)
+	}
+}
+
+func testTornLastLogBlock(t *testing.T, fs *storage.MemFS, dm *storage.DiskManager, encrypted bool) {
+	lm, err := NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	lsns := make([]int, 500)
+	for i := range lsns {
+		lsns[i], err = lm.Append(createLogMessage(fmt.Sprintf("lintang %d", i)))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, lm.Flush(lsns[len(lsns)-1]))
+	last := lm.currentBlockID.GetBlockNum()
+	assert.Greater(t, last, 0)
+
+	// simulasi crash pas block log terakhir ditulis ulang tanpa doublewrite: isi block tidak cocok dengan checksum nya,
+	// atau (log terenkripsi) nonce di file .enc tidak cocok dengan ciphertext nya. entry .enc: [key version (4)] [nonce (12)] [tag (16)]
+	file, off := "lintangdb_torn/lintangdb.log", last*4096+4000
+	if encrypted {
+		file, off = "lintangdb_torn/lintangdb.log.enc", last*(4+12+16)+4
+	}
+	f, err := fs.OpenFile(file, os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff, 0xff}, int64(off))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	// reopen: block torn dianggap akhir log, record di block-block sebelumnya tetap ada
+	lm, err = NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	assert.Greater(t, lm.LatestLSN(), lsns[len(lsns)-1])
+	after, err := lm.Append(createLogMessage("after"))
+	assert.NoError(t, err)
+	var want []string
+	for i, lsn := range lsns {
+		if lsn <= last*4096 {
+			want = append(want, fmt.Sprintf("lintang %d", i))
+		}
+	}
+	assert.NotEmpty(t, want)
+	want = append(want, "after")
+	lit, err := lm.GetIterator()
+	assert.NoError(t, err)
+	assert.Equal(t, want, collectLog(t, lit))
+
+	lm, err = NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	assert.Equal(t, after, lm.LatestLSN())
+	record, err := lm.ReadAt(after)
+	assert.NoError(t, err)
+	assert.Equal(t, createLogMessage("after"), record)
+	assert.NoError(t, dm.Close())
+}
+
+func TestPageImageRecords(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log")
+	assert.NoError(t, err)
+	_, err = lm.Append(createLogMessage("before"))
+	assert.NoError(t, err)
+	startLSN := lm.LatestLSN()
+
+	// page image satu record, dipotong jadi chunk di beberapa block log
+	page := storage.NewPage(4096)
+	page.PutString(0, "lintang")
+	page.PutString(4000, "birda")
+	dm.SetPageLogger(lm)
+	assert.NoError(t, dm.Write(storage.NewBlockID("test.db", 3), page))
+	dm.SetPageLogger(nil)
+	assert.NoError(t, dm.Write(storage.NewBlockID("test.db", 4), page))
+
+	records, endLSN, err := lm.RecordsSince(startLSN)
+	assert.NoError(t, err)
+	assert.Equal(t, lm.LatestLSN(), endLSN)
+	assert.Len(t, records, 1)
+
+	restored := storage.NewPage(4096)
+	for _, record := range records {
+		assert.Equal(t, PageImageRecord, GetRecordType(record.Data))
+		img, err := DecodePageImage(record.Data)
+		assert.NoError(t, err)
+		assert.Equal(t, storage.NewBlockID("test.db", 3), img.BlockID)
+		assert.NoError(t, img.Apply(restored))
+	}
+	assert.Equal(t, page.Contents(), restored.Contents())
+
+	_, err = DecodePageImage(createLogMessage("before"))
+	assert.ErrorIs(t, err, ErrCorruptRecord)
+	_, _, err = lm.RecordsSince(endLSN + 1)
+	assert.ErrorIs(t, err, ErrLSNOutOfRange)
+}
+
+func TestCommitRecord(t *testing.T) {
+	now := time.Now()
+	commit, err := DecodeCommit(Commit{TxNum: 7, Time: now}.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, 7, commit.TxNum)
+	assert.True(t, now.Equal(commit.Time))
+	_, err = DecodeCommit(NewBackupCheckpoint())
+	assert.ErrorIs(t, err, ErrCorruptRecord)
+}
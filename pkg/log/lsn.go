@@ -0,0 +1,71 @@
+package log
+
+import (
+	"fmt"
+)
+
+/*
+LSN. posisi log record di log: (segment, block, offset) yang dipack jadi satu int.
+
+	LSN = segment << segmentLSNShift + blockNum * blockSize + (blockSize - offset)
+
+offset posisi record di block. record di block ditulis dari kanan ke kiri, jadi (blockSize - offset) membesar untuk record yang di append belakangan
+& LSN selalu naik, termasuk setelah log dibuka ulang (LSN dihitung dari posisi record, bukan counter di memori) & setelah segment lama dihapus.
+nilai (blockSize - offset) record selalu di antara 1 dan blockSize-1, jadi LSN kelipatan blockSize berarti posisi sebelum record pertama di block.
+log yang tidak dibagi jadi segment (dan log file yang bukan segment) dianggap segment 0.
+*/
+
+// segmentLSNShift. jumlah bit posisi record di dalam satu segment (maksimal 1TB per segment).
+const segmentLSNShift = 40
+
+// makeLSN. return LSN record di offset pos block blockNum file log file. pos == blockSize artinya posisi sebelum record pertama di block.
+func makeLSN(file string, blockNum, blockSize, pos int) int {
+	segment := 0
+	if _, n, ok := parseSegmentFile(file); ok {
+		segment = n
+	}
+	return segment<<segmentLSNShift + blockNum*blockSize + blockSize - pos
+}
+
+// splitLSN. return nomor segment, nomor block & offset record LSN lsn di log dengan block size blockSize.
+func splitLSN(lsn, blockSize int) (segment, blockNum, pos int) {
+	segment = lsn >> segmentLSNShift
+	rest := lsn & (1<<segmentLSNShift - 1)
+	return segment, rest / blockSize, blockSize - rest%blockSize
+}
+
+// firstLSN. return LSN sebelum record pertama yang masih ada di log. caller harus hold lm.mu.
+func (lm *LogManager) firstLSN() int {
+	return lm.firstSegment << segmentLSNShift
+}
+
+// ReadAt. return log record dengan LSN lsn. return ErrLSNOutOfRange kalau tidak ada record yang mulai di lsn (termasuk record di segment yang sudah dihapus).
+func (lm *LogManager) ReadAt(lsn int) ([]byte, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn <= lm.firstLSN() || lsn > lm.latestLSN {
+		return nil, fmt.Errorf("%w: %d", ErrLSNOutOfRange, lsn)
+	}
+	if lsn > lm.lastSavedLSN {
+		err := lm.flush() // record yang belum diwrite ikut terbaca
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// record pertama setelah lsn-1 harus mulai tepat di lsn
+	fit, err := NewForwardLogIterator(lm.diskManager, lm.logFile, lsn-1)
+	if err != nil {
+		return nil, err
+	}
+	for record := range fit.IterateLog() {
+		if fit.LSN() == lsn {
+			return record, nil
+		}
+		break
+	}
+	if fit.GetError() != nil {
+		return nil, fit.GetError()
+	}
+	return nil, fmt.Errorf("%w: %d is not the start of a log record", ErrLSNOutOfRange, lsn)
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLSNAcrossRestart(t *testing.T) {
+	for _, opts := range [][]LogManagerOption{nil, {WithSegmentSize(2)}} {
+		fs := storage.NewMemFS()
+		dm, err := storage.NewDiskManager("lintangdb_lsn", 4096, storage.WithVFS(fs))
+		assert.NoError(t, err)
+		lm, err := NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+		createLogRecordAndAppendToLogFile(t, lm, 0, 500)
+		lsns := make(map[int]string)
+		lit, err := lm.GetIterator()
+		assert.NoError(t, err)
+		for record := range lit.IterateLog() {
+			lsns[lit.LSN()] = storage.NewPageFromByteSlice(record).GetString(0)
+		}
+		assert.Len(t, lsns, 500)
+		latest := lm.LatestLSN()
+		assert.NoError(t, dm.Close())
+
+		// LSN lanjut dari posisi record terakhir, record lama tetap bisa dibaca dengan LSN nya
+		dm, err = storage.NewDiskManager("lintangdb_lsn", 4096, storage.WithVFS(fs))
+		assert.NoError(t, err)
+		lm, err = NewLogManager(dm, "lintangdb.log", opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, latest, lm.LatestLSN())
+		createLogRecordAndAppendToLogFile(t, lm, 500, 600)
+		for lsn, msg := range lsns {
+			record, err := lm.ReadAt(lsn)
+			assert.NoError(t, err)
+			assert.Equal(t, msg, storage.NewPageFromByteSlice(record).GetString(0))
+		}
+
+		records, endLSN, err := lm.RecordsSince(latest)
+		assert.NoError(t, err)
+		assert.Equal(t, lm.LatestLSN(), endLSN)
+		assert.Len(t, records, 100)
+		for i, record := range records {
+			assert.Equal(t, fmt.Sprintf("lintang %d", 500+i), storage.NewPageFromByteSlice(record.Data).GetString(0))
+			data, err := lm.ReadAt(record.LSN)
+			assert.NoError(t, err)
+			assert.Equal(t, record.Data, data)
+		}
+
+		for _, lsn := range []int{0, latest + 1, lm.LatestLSN() + 1, -1} {
+			_, err = lm.ReadAt(lsn)
+			assert.ErrorIs(t, err, ErrLSNOutOfRange, "lsn %d", lsn)
+		}
+		assert.NoError(t, dm.Close())
+	}
+}
+
+func TestReadAtAfterCheckpoint(t *testing.T) {
+	dm, err := storage.NewDiskManager("lintangdb_lsn_checkpoint", 4096, storage.WithVFS(storage.NewMemFS()))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithSegmentSize(2))
+	assert.NoError(t, err)
+	first, err := lm.Append(createLogMessage("first"))
+	assert.NoError(t, err)
+	createLogRecordAndAppendToLogFile(t, lm, 0, 1000)
+	checkpoint, err := lm.Checkpoint()
+	assert.NoError(t, err)
+
+	_, err = lm.ReadAt(first)
+	assert.ErrorIs(t, err, ErrLSNOutOfRange)
+	_, _, err = lm.RecordsSince(first)
+	assert.ErrorIs(t, err, ErrLSNOutOfRange)
+	record, err := lm.ReadAt(checkpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, CheckpointRecord, GetRecordType(record))
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+// RecordType. tipe log record, disimpan di 4 byte pertama record.
+type RecordType int
+
+const (
+	// PageImageRecord. isi block yang diwrite ke disk selama page logging (lihat LogManager.LogPage).
+	PageImageRecord RecordType = iota + 1
+	// BackupCheckpointRecord. batas backup: block yang diwrite setelah record ini punya block LSN >= LSN record ini (lihat backup.Info.CutoffLSN).
+	BackupCheckpointRecord
+	// CommitRecord. commit transaksi beserta waktu commit nya (buat point-in-time recovery ke waktu tertentu).
+	CommitRecord
+	// CheckpointRecord. checkpoint (lihat LogManager.Checkpoint): semua perubahan sebelum record ini sudah ada di file block.
+	CheckpointRecord
+)
+
+// ErrCorruptRecord. dikembalikan decode log record kalau isi record tidak valid.
+var ErrCorruptRecord = errors.New("corrupt log record")
+
+/*
+PageImage. isi block (atau potongan isi block, di log yang ditulis sebelum record bisa lebih besar dari block log): Data ditulis di block BlockID mulai offset Offset.
+format record: [type (4)] [filename length (4)] [filename] [blockNum (4)] [offset (4)] [data length (4)] [data]
+*/
+type PageImage struct {
+	BlockID storage.BlockID
+	Offset  int
+	Data    []byte
+}
+
+// pageImageHeaderSize. ukuran record page image tanpa filename & data.
+const pageImageHeaderSize = 20
+
+// GetRecordType. return tipe log record.
+func GetRecordType(record []byte) RecordType {
+	if len(record) < 4 {
+		return 0
+	}
+	return RecordType(storage.NewPageFromByteSlice(record).GetInt(0))
+}
+
+// NewBackupCheckpoint. return log record backup checkpoint. format record: [type (4)].
+func NewBackupCheckpoint() []byte {
+	page := storage.NewPageFromByteSlice(make([]byte, 4))
+	page.PutInt(0, int(BackupCheckpointRecord))
+	return page.Contents()
+}
+
+// NewCheckpoint. return log record checkpoint. format record: [type (4)].
+func NewCheckpoint() []byte {
+	page := storage.NewPageFromByteSlice(make([]byte, 4))
+	page.PutInt(0, int(CheckpointRecord))
+	return page.Contents()
+}
+
+/*
+Commit. commit transaksi TxNum pada waktu Time.
+format record: [type (4)] [txNum (4)] [time, unix nano (8)]
+*/
+type Commit struct {
+	TxNum int
+	Time  time.Time
+}
+
+// commitRecordSize. ukuran record commit.
+const commitRecordSize = 16
+
+// Encode. encode commit jadi log record.
+func (c Commit) Encode() []byte {
+	record := make([]byte, commitRecordSize)
+	page := storage.NewPageFromByteSlice(record)
+	page.PutInt(0, int(CommitRecord))
+	page.PutInt(4, c.TxNum)
+	binary.LittleEndian.PutUint64(record[8:], uint64(c.Time.UnixNano()))
+	return record
+}
+
+// DecodeCommit. decode log record commit.
+func DecodeCommit(record []byte) (Commit, error) {
+	if GetRecordType(record) != CommitRecord || len(record) != commitRecordSize {
+		return Commit{}, fmt.Errorf("%w: not a commit", ErrCorruptRecord)
+	}
+	page := storage.NewPageFromByteSlice(record)
+	return Commit{
+		TxNum: page.GetInt(4),
+		Time:  time.Unix(0, int64(binary.LittleEndian.Uint64(record[8:]))),
+	}, nil
+}
+
+// Encode. encode page image jadi log record.
+func (img PageImage) Encode() []byte {
+	filename := img.BlockID.GetFilename()
+	page := storage.NewPageFromByteSlice(make([]byte, pageImageHeaderSize+len(filename)+len(img.Data)))
+	page.PutInt(0, int(PageImageRecord))
+	page.PutString(4, filename)
+	pos := 8 + len(filename)
+	page.PutInt(pos, img.BlockID.GetBlockNum())
+	page.PutInt(pos+4, img.Offset)
+	page.PutBytes(pos+8, img.Data)
+	return page.Contents()
+}
+
+// Apply. tulis data page image ke page.
+func (img PageImage) Apply(page *storage.Page) error {
+	if img.Offset+len(img.Data) > len(page.Contents()) {
+		return fmt.Errorf("%w: page image of block %d of %s out of page bounds", ErrCorruptRecord, img.BlockID.GetBlockNum(), img.BlockID.GetFilename())
+	}
+	copy(page.Contents()[img.Offset:], img.Data)
+	return nil
+}
+
+// DecodePageImage. decode log record page image.
+func DecodePageImage(record []byte) (PageImage, error) {
+	if GetRecordType(record) != PageImageRecord || len(record) < pageImageHeaderSize {
+		return PageImage{}, fmt.Errorf("%w: not a page image", ErrCorruptRecord)
+	}
+	page := storage.NewPageFromByteSlice(record)
+	nameLen := page.GetInt(4)
+	if nameLen > len(record)-pageImageHeaderSize {
+		return PageImage{}, fmt.Errorf("%w: truncated page image", ErrCorruptRecord)
+	}
+	filename := string(record[8 : 8+nameLen])
+	pos := 8 + nameLen
+	dataLen := page.GetInt(pos + 8)
+	if dataLen != len(record)-pageImageHeaderSize-nameLen {
+		return PageImage{}, fmt.Errorf("%w: truncated page image", ErrCorruptRecord)
+	}
+	return PageImage{
+		BlockID: storage.NewBlockID(filename, page.GetInt(pos)),
+		Offset:  page.GetInt(pos + 4),
+		Data:    page.GetBytes(pos + 8),
+	}, nil
+}
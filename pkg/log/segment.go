@@ -0,0 +1,235 @@
+package log
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+)
+
+/*
+segment log. dengan WithSegmentSize, log dibagi jadi file segment berukuran tetap <logFile>.000000, <logFile>.000001, ... .
+record selalu di append ke segment terakhir, kalau segment sudah penuh dibuat segment baru. LogIterator pindah ke segment sebelumnya
+kalau sudah sampai block pertama segment.
+setelah Checkpoint, segment sebelum segment tempat record checkpoint tidak diperlukan lagi: dipindah ke archive (WithSegmentArchive) atau dihapus.
+*/
+
+// segmentDigits. jumlah digit nomor segment di nama file segment.
+const segmentDigits = 6
+
+// WithSegmentSize. bagi log jadi segment berisi blocks block. 0 (default) artinya log satu file yang terus membesar.
+func WithSegmentSize(blocks int) LogManagerOption {
+	return func(lm *LogManager) {
+		lm.segmentSize = max(blocks, 0)
+	}
+}
+
+// WithSegmentArchive. segment yang tidak diperlukan lagi setelah Checkpoint dicopy ke archive (mis. DiskManager di directory archive) sebelum dihapus.
+func WithSegmentArchive(archive storage.BlockManager) LogManagerOption {
+	return func(lm *LogManager) {
+		lm.archive = archive
+	}
+}
+
+// SegmentFile. return nama file segment log nomor n dari log file logFile.
+func SegmentFile(logFile string, n int) string {
+	return fmt.Sprintf("%s.%0*d", logFile, segmentDigits, n)
+}
+
+// parseSegmentFile. return nama log file & nomor segment dari nama file segment. ok false kalau filename bukan file segment.
+func parseSegmentFile(filename string) (string, int, bool) {
+	i := strings.LastIndexByte(filename, '.')
+	if i < 0 || len(filename)-i-1 != segmentDigits {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(filename[i+1:])
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return filename[:i], n, true
+}
+
+// segmentNums. return nomor semua segment log file logFile di bm, urut dari yang paling lama.
+func segmentNums(bm storage.BlockManager, logFile string) []int {
+	var nums []int
+	for _, name := range bm.Files() {
+		if base, n, ok := parseSegmentFile(name); ok && base == logFile {
+			nums = append(nums, n)
+		}
+	}
+	slices.Sort(nums)
+	return nums
+}
+
+// openSegments. cari segment log yang sudah ada (segment pertama dibuat kalau belum ada) & return file tempat record di append.
+func (lm *LogManager) openSegments() (string, error) {
+	if lm.segmentSize == 0 {
+		return lm.logFile, nil
+	}
+	nums := segmentNums(lm.diskManager, lm.logFile)
+	if len(nums) == 0 {
+		file := SegmentFile(lm.logFile, 0)
+		return file, lm.diskManager.CreateFile(file, lm.diskManager.FileBlockSize(lm.logFile))
+	}
+	lm.firstSegment, lm.currentSegment = nums[0], nums[len(nums)-1]
+	return SegmentFile(lm.logFile, lm.currentSegment), nil
+}
+
+// nextSegment. buat segment berikutnya. block terakhir segment sekarang masih pending, diwrite & di fsync pas Flush berikutnya. caller harus hold lm.mu.
+func (lm *LogManager) nextSegment() (string, error) {
+	file := SegmentFile(lm.logFile, lm.currentSegment+1)
+	err := lm.diskManager.CreateFile(file, len(lm.logPage.Contents()))
+	if err != nil {
+		return "", err
+	}
+	lm.currentSegment++
+	return file, nil
+}
+
+// Segments. return file segment log yang masih ada, urut dari yang paling lama. return log file kalau log tidak dibagi jadi segment.
+func (lm *LogManager) Segments() []string {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lm.segmentSize == 0 {
+		return []string{lm.logFile}
+	}
+	segments := make([]string, 0, lm.currentSegment-lm.firstSegment+1)
+	for n := lm.firstSegment; n <= lm.currentSegment; n++ {
+		segments = append(segments, SegmentFile(lm.logFile, n))
+	}
+	return segments
+}
+
+/*
+Checkpoint. append record checkpoint & tunggu sampai persist, lalu pindahkan ke archive / hapus segment log sebelum segment tempat record checkpoint.
+caller harus sudah write semua block yang berubah (mis. flush semua buffer) sebelum Checkpoint, karena log sebelum checkpoint dibuang.
+iterator / backup yang sedang membaca segment lama bisa gagal. return LSN record checkpoint.
+*/
+func (lm *LogManager) Checkpoint() (int, error) {
+	lm.checkpointMu.Lock()
+	defer lm.checkpointMu.Unlock()
+
+	lsn, err := lm.append(NewCheckpoint())
+	if err != nil {
+		return 0, err
+	}
+	err = lm.Flush(lsn)
+	if err != nil {
+		return 0, err
+	}
+
+	// batas dihitung dari LSN checkpoint, bukan currentSegment: goroutine lain bisa sudah append ke segment yang lebih baru
+	lm.mu.Lock()
+	first := lm.firstSegment
+	segment, _, _ := splitLSN(lsn, len(lm.logPage.Contents()))
+	lm.mu.Unlock()
+	for n := first; n < segment && lm.segmentSize > 0; n++ {
+		err = lm.removeSegment(SegmentFile(lm.logFile, n))
+		if err != nil {
+			return 0, err
+		}
+		lm.mu.Lock()
+		lm.firstSegment = n + 1
+		lm.mu.Unlock()
+	}
+	return lsn, nil
+}
+
+// removeSegment. copy segment ke archive (kalau ada) lalu hapus segment.
+func (lm *LogManager) removeSegment(file string) error {
+	if lm.archive != nil {
+		err := copyLogBlocks(lm.diskManager, lm.archive, file, 0)
+		if err != nil {
+			return fmt.Errorf("failed to archive log segment %s: %w", file, err)
+		}
+	}
+	return lm.diskManager.DeleteFile(file)
+}
+
+// copyLogBlocks. copy block file mulai block from sampai block terakhir dari src ke dst (di posisi yang sama) & fsync.
+func copyLogBlocks(src, dst storage.BlockManager, file string, from int) error {
+	blockSize := src.FileBlockSize(file)
+	err := dst.CreateFile(file, blockSize)
+	if err != nil {
+		return err
+	}
+	numBlocks, err := src.BlockLength(file)
+	if err != nil {
+		return err
+	}
+	page := storage.NewPage(blockSize)
+	for i := from; i < numBlocks; i++ {
+		blockID := storage.NewBlockID(file, i)
+		err = src.Read(blockID, page)
+		if err != nil {
+			return err
+		}
+		err = dst.Write(blockID, page)
+		if err != nil {
+			return err
+		}
+	}
+	return dst.SyncFile(file)
+}
+
+/*
+ArchiveTo. flush log & copy block log yang berisi record dengan LSN > sinceLSN ke dst. file & posisi block di dst sama dengan di log,
+jadi LSN record di dst sama (baca dengan ReadRecords). block terakhir yang sudah dicopy sebelumnya ditimpa. return LSN record terakhir.
+kalau dst archive segment (WithSegmentArchive), record di segment yang sudah dipindah Checkpoint ke dst tidak dicopy ulang.
+*/
+func (lm *LogManager) ArchiveTo(dst storage.BlockManager, sinceLSN int) (int, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lm.archive != nil && dst == lm.archive {
+		sinceLSN = max(sinceLSN, lm.firstLSN()) // segment yang sudah dihapus sudah dicopy ke dst oleh Checkpoint
+	}
+	if sinceLSN < lm.firstLSN() || sinceLSN > lm.latestLSN {
+		return 0, fmt.Errorf("%w: %d (log has lsn %d-%d)", ErrLSNOutOfRange, sinceLSN, lm.firstLSN(), lm.latestLSN)
+	}
+	err := lm.flush()
+	if err != nil {
+		return 0, err
+	}
+	segment, blockNum, _ := splitLSN(sinceLSN, len(lm.logPage.Contents()))
+	if lm.segmentSize == 0 {
+		return lm.latestLSN, copyLogBlocks(lm.diskManager, dst, lm.logFile, blockNum)
+	}
+	for n := segment; n <= lm.currentSegment; n++ {
+		err = copyLogBlocks(lm.diskManager, dst, SegmentFile(lm.logFile, n), blockNum)
+		if err != nil {
+			return 0, err
+		}
+		blockNum = 0
+	}
+	return lm.latestLSN, nil
+}
+
+// logFiles. return log file / segment log logFile di bm, urut dari yang paling lama.
+func logFiles(bm storage.BlockManager, logFile string) []string {
+	nums := segmentNums(bm, logFile)
+	if len(nums) == 0 {
+		if slices.Contains(bm.Files(), logFile) {
+			return []string{logFile}
+		}
+		return nil
+	}
+	files := make([]string, len(nums))
+	for i, n := range nums {
+		files[i] = SegmentFile(logFile, n)
+	}
+	return files
+}
+
+/*
+ReadRecords. read log record dengan LSN > sinceLSN dari log logFile di bm (log database / archive hasil ArchiveTo & WithSegmentArchive),
+urut dari yang terdahulu. return ErrLSNOutOfRange kalau record setelah sinceLSN sudah tidak ada di bm.
+*/
+func ReadRecords(bm storage.BlockManager, logFile string, sinceLSN int) ([]Record, error) {
+	fit, err := NewForwardLogIterator(bm, logFile, sinceLSN)
+	if err != nil {
+		return nil, err
+	}
+	return fit.records()
+}
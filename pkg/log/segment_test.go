@@ -0,0 +1,167 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// collectLog. return isi semua log record dari iterator, urut dari yang terdahulu.
+func collectLog(t *testing.T, lit *LogIterator) []string {
+	var records []string
+	for record := range lit.IterateLog() {
+		msg := "checkpoint"
+		if GetRecordType(record) != CheckpointRecord {
+			msg = storage.NewPageFromByteSlice(record).GetString(0)
+		}
+		records = append([]string{msg}, records...)
+	}
+	assert.NoError(t, lit.GetError())
+	return records
+}
+
+func logMessages(start, end int) []string {
+	var msgs []string
+	for i := start; i < end; i++ {
+		msgs = append(msgs, fmt.Sprintf("lintang %d", i))
+	}
+	return msgs
+}
+
+func TestLogSegments(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_segment", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithSegmentSize(2))
+	assert.NoError(t, err)
+	createLogRecordAndAppendToLogFile(t, lm, 0, 1000)
+
+	segments := lm.Segments()
+	assert.Greater(t, len(segments), 2)
+	assert.Equal(t, SegmentFile("lintangdb.log", 0), segments[0])
+	for _, segment := range segments {
+		assert.True(t, lm.IsLogFile(segment))
+		n, err := dm.BlockLength(segment)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, n, 2)
+	}
+	assert.NotContains(t, dm.Files(), "lintangdb.log")
+
+	// iterator pindah segment sampai segment pertama
+	lit, err := lm.GetIterator()
+	assert.NoError(t, err)
+	assert.Equal(t, logMessages(0, 1000), collectLog(t, lit))
+
+	// reopen: record di append ke segment terakhir
+	lm, err = NewLogManager(dm, "lintangdb.log", WithSegmentSize(2))
+	assert.NoError(t, err)
+	assert.Equal(t, segments, lm.Segments())
+	for i := 1000; i < 1200; i++ {
+		_, err = lm.Append(createLogMessage(fmt.Sprintf("lintang %d", i)))
+		assert.NoError(t, err)
+	}
+	lit, err = lm.GetIterator()
+	assert.NoError(t, err)
+	assert.Equal(t, logMessages(0, 1200), collectLog(t, lit))
+}
+
+func TestCheckpointTruncatesSegments(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_checkpoint", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	archive, err := storage.NewDiskManager("lintangdb_checkpoint_archive", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithSegmentSize(2), WithSegmentArchive(archive))
+	assert.NoError(t, err)
+	createLogRecordAndAppendToLogFile(t, lm, 0, 1000)
+	segments := lm.Segments()
+
+	_, err = lm.Checkpoint()
+	assert.NoError(t, err)
+	current := segments[len(segments)-1]
+	assert.Equal(t, []string{current}, lm.Segments())
+	for _, segment := range segments[:len(segments)-1] {
+		assert.NotContains(t, dm.Files(), segment)
+		assert.Contains(t, archive.Files(), segment)
+	}
+
+	// log sekarang cuma mulai dari segment checkpoint, sisanya ada di archive
+	lit, err := lm.GetIterator()
+	assert.NoError(t, err)
+	live := collectLog(t, lit)
+	numArchived, err := archive.BlockLength(segments[len(segments)-2])
+	assert.NoError(t, err)
+	lit, err = NewLogIterator(archive, storage.NewBlockID(segments[len(segments)-2], numArchived-1))
+	assert.NoError(t, err)
+	archived := collectLog(t, lit)
+	assert.Equal(t, "checkpoint", live[len(live)-1])
+	assert.Equal(t, logMessages(0, 1000), append(archived, live[:len(live)-1]...))
+
+	// tanpa archive segment lama dihapus
+	for i := 1000; i < 1300; i++ {
+		_, err = lm.Append(createLogMessage(fmt.Sprintf("lintang %d", i)))
+		assert.NoError(t, err)
+	}
+	lm.archive = nil
+	_, err = lm.Checkpoint()
+	assert.NoError(t, err)
+	assert.Len(t, lm.Segments(), 1)
+	assert.Len(t, archive.Files(), len(segments)-1)
+}
+
+func TestCheckpointConcurrentAppend(t *testing.T) {
+	fs := storage.NewMemFS()
+	dm, err := storage.NewDiskManager("lintangdb_checkpoint_append", 4096, storage.WithVFS(fs))
+	assert.NoError(t, err)
+	lm, err := NewLogManager(dm, "lintangdb.log", WithSegmentSize(1), WithGroupCommitDelay(time.Millisecond))
+	assert.NoError(t, err)
+
+	var (
+		mu   sync.Mutex
+		lsns []int
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3000; i++ {
+			lsn, err := lm.Append(createLogMessage(fmt.Sprintf("lintang %d", i)))
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			lsns = append(lsns, lsn)
+			mu.Unlock()
+		}
+	}()
+
+	// record checkpoint & record yang di append setelahnya tidak boleh ikut terhapus walaupun writer sudah pindah segment
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		checkpointLSN, err := lm.Checkpoint()
+		assert.NoError(t, err)
+		record, err := lm.ReadAt(checkpointLSN)
+		assert.NoError(t, err)
+		assert.Equal(t, CheckpointRecord, GetRecordType(record))
+
+		mu.Lock()
+		var after []int
+		for _, lsn := range lsns {
+			if lsn > checkpointLSN {
+				after = append(after, lsn)
+			}
+		}
+		mu.Unlock()
+		for _, lsn := range after {
+			_, err = lm.ReadAt(lsn)
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// IOEngineKind. jenis async I/O engine yang dipakai ReadAsync/WriteAsync.
+type IOEngineKind int
+
+const (
+	IOEngineAuto       IOEngineKind = iota // io_uring kalau tersedia (linux), kalau tidak pakai thread pool
+	IOEngineThreadPool                     // pread/pwrite di goroutine pool
+)
+
+var errIOEngineClosed = errors.New("io engine closed")
+
+// ioEngine. engine yang jalanin read/write file secara async. done dipanggil dari goroutine engine setelah I/O selesai.
+type ioEngine interface {
+	submit(f File, buf []byte, off int64, write bool, done func(n int, err error))
+	close() error
+}
+
+// WithIOEngine. pilih async I/O engine yang dipakai ReadAsync/WriteAsync. default IOEngineAuto.
+func WithIOEngine(kind IOEngineKind) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.ioEngineKind = kind
+	}
+}
+
+// IORequest. satu read/write block yang disubmit async. panggil Wait buat menunggu I/O selesai.
+type IORequest struct {
+	done chan struct{}
+	err  error
+}
+
+// Done. channel yang diclose kalau I/O sudah selesai.
+func (req *IORequest) Done() <-chan struct{} {
+	return req.done
+}
+
+// Wait. tunggu I/O selesai & return errornya.
+func (req *IORequest) Wait() error {
+	<-req.done
+	return req.err
+}
+
+// ReadAsync. submit read block ke async I/O engine & langsung return. page baru boleh dipakai setelah Wait.
+// checksum block diverifikasi sebelum request selesai.
+func (dm *DiskManager) ReadAsync(blockID BlockID, page *Page) *IORequest {
+	return dm.submitIO(blockID, page, false)
+}
+
+// WriteAsync. submit write block ke async I/O engine & langsung return. page tidak boleh diubah sampai Wait.
+// checksum block disimpan sebelum request selesai.
+func (dm *DiskManager) WriteAsync(blockID BlockID, page *Page) *IORequest {
+	return dm.submitIO(blockID, page, true)
+}
+
+// submitIO. submit I/O block ke engine. latch block dihold dari submit sampai I/O & checksum selesai (dilepas di goroutine engine).
+func (dm *DiskManager) submitIO(blockID BlockID, page *Page, write bool) *IORequest {
+	req := &IORequest{done: make(chan struct{})}
+	if write && dm.readOnly {
+		req.err = fmt.Errorf("%w: cannot write %s", ErrReadOnly, blockID.GetFilename())
+		close(req.done)
+		return req
+	}
+	err := dm.checkPageSize(blockID, page)
+	if err != nil {
+		req.err = err
+		close(req.done)
+		return req
+	}
+	transformed, err := dm.isTransformedFile(blockID.GetFilename())
+	if err != nil {
+		req.err = err
+		close(req.done)
+		return req
+	}
+	if transformed {
+		// block yang dicompress / dienkripsi diproses di luar engine, read/write sync di goroutine terpisah
+		go func() {
+			if write {
+				req.err = dm.Write(blockID, page)
+			} else {
+				req.err = dm.Read(blockID, page)
+			}
+			close(req.done)
+		}()
+		return req
+	}
+
+	df, err := dm.acquireFile(blockID.GetFilename()) // file dilepas setelah I/O selesai
+	if err != nil {
+		req.err = err
+		close(req.done)
+		return req
+	}
+
+	latch := df.blockLatch(blockID.GetBlockNum())
+	lsn := -1
+	if write {
+		latch.Lock()
+		err = dm.logPage(blockID, page.Contents())
+		if err != nil {
+			latch.Unlock()
+			dm.releaseFile(df)
+			req.err = err
+			close(req.done)
+			return req
+		}
+		lsn = dm.blockLSN(blockID)
+		df.dirty.Store(true)
+	} else {
+		latch.RLock()
+	}
+	contents := page.Contents()
+	buf := contents
+	bounce := df.direct && !isAligned(contents)
+	if bounce {
+		// O_DIRECT butuh buffer aligned
+		buf = alignedBuffer(len(contents))
+		copy(buf, contents)
+	}
+	dm.getIOEngine().submit(df.f, buf, int64(blockID.GetBlockNum()*dm.FileBlockSize(blockID.GetFilename())), write, func(n int, err error) {
+		if bounce && !write {
+			copy(contents, buf[:n])
+		}
+		if write {
+			if err == nil {
+				err = dm.writeChecksum(blockID, contents)
+			}
+			if err == nil && lsn >= 0 {
+				err = dm.writeBlockLSN(blockID, lsn)
+			}
+			latch.Unlock()
+		} else {
+			err = dm.completeRead(blockID, contents, n, err)
+			latch.RUnlock()
+		}
+		dm.releaseFile(df)
+		req.err = err
+		close(req.done)
+	})
+	return req
+}
+
+// completeRead. proses hasil read async: zero-fill block yang tidak penuh & verifikasi checksum.
+func (dm *DiskManager) completeRead(blockID BlockID, contents []byte, n int, err error) error {
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n < len(contents) {
+		if n == 0 {
+			return io.EOF
+		}
+		// block terakhir file tidak penuh, sisanya diisi 0
+		clear(contents[n:])
+	}
+	if dm.verifyChecksum {
+		return dm.validateChecksum(blockID, contents)
+	}
+	return nil
+}
+
+// getIOEngine. buat async I/O engine pas pertama kali dipakai.
+func (dm *DiskManager) getIOEngine() ioEngine {
+	dm.ioEngineLatch.Lock()
+	defer dm.ioEngineLatch.Unlock()
+	if dm.ioEngine != nil {
+		return dm.ioEngine
+	}
+	switch dm.ioEngineKind {
+	case IOEngineThreadPool:
+		dm.ioEngine = newPoolEngine(runtime.GOMAXPROCS(0))
+	default:
+		engine, err := newUringEngine()
+		if err != nil {
+			// io_uring tidak tersedia (kernel lama / bukan linux / diblock seccomp)
+			engine = newPoolEngine(runtime.GOMAXPROCS(0))
+		}
+		dm.ioEngine = engine
+	}
+	return dm.ioEngine
+}
+
+// closeIOEngine. stop async I/O engine kalau sudah pernah dibuat. engine dibuat lagi kalau async I/O dipakai lagi.
+func (dm *DiskManager) closeIOEngine() error {
+	dm.ioEngineLatch.Lock()
+	defer dm.ioEngineLatch.Unlock()
+	if dm.ioEngine == nil {
+		return nil
+	}
+	err := dm.ioEngine.close()
+	dm.ioEngine = nil
+	return err
+}
+
+// poolEngine. async I/O pakai pread/pwrite (ReadAt/WriteAt) di beberapa goroutine worker.
+type poolEngine struct {
+	mu     sync.RWMutex
+	jobs   chan poolJob
+	wg     sync.WaitGroup
+	closed bool
+}
+
+type poolJob struct {
+	f     File
+	buf   []byte
+	off   int64
+	write bool
+	done  func(n int, err error)
+}
+
+func newPoolEngine(workers int) *poolEngine {
+	e := &poolEngine{jobs: make(chan poolJob, workers*4)}
+	for i := 0; i < workers; i++ {
+		e.wg.Add(1)
+		go e.worker()
+	}
+	return e
+}
+
+func (e *poolEngine) worker() {
+	defer e.wg.Done()
+	for job := range e.jobs {
+		var n int
+		var err error
+		if job.write {
+			n, err = job.f.WriteAt(job.buf, job.off)
+		} else {
+			n, err = job.f.ReadAt(job.buf, job.off)
+		}
+		job.done(n, err)
+	}
+}
+
+func (e *poolEngine) submit(f File, buf []byte, off int64, write bool, done func(n int, err error)) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		done(0, errIOEngineClosed)
+		return
+	}
+	e.jobs <- poolJob{f: f, buf: buf, off: off, write: write, done: done}
+}
+
+func (e *poolEngine) close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.jobs)
+	e.mu.Unlock()
+	e.wg.Wait()
+	return nil
+}
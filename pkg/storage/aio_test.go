@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncIO(t *testing.T) {
+	engines := []struct {
+		name string
+		opts []DiskManagerOption
+	}{
+		{"auto", []DiskManagerOption{WithIOEngine(IOEngineAuto)}},
+		{"thread pool", []DiskManagerOption{WithIOEngine(IOEngineThreadPool)}},
+		{"memfs", []DiskManagerOption{WithVFS(NewMemFS())}},
+	}
+
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			os.RemoveAll("lintangdb_aio")
+			defer os.RemoveAll("lintangdb_aio")
+
+			dm, err := NewDiskManager("lintangdb_aio", 4096, engine.opts...)
+			assert.NoError(t, err)
+			defer dm.Close()
+
+			// submit semua write dulu, baru tunggu
+			reqs := make([]*IORequest, 16)
+			for i := range reqs {
+				page := NewPage(4096)
+				page.PutString(0, fmt.Sprintf("lintang%d", i))
+				reqs[i] = dm.WriteAsync(NewBlockID("test.db", i), page)
+			}
+			for _, req := range reqs {
+				assert.NoError(t, req.Wait())
+			}
+
+			pages := make([]*Page, len(reqs))
+			for i := range reqs {
+				pages[i] = NewPage(4096)
+				reqs[i] = dm.ReadAsync(NewBlockID("test.db", i), pages[i])
+			}
+			for i, req := range reqs {
+				assert.NoError(t, req.Wait())
+				assert.Equal(t, fmt.Sprintf("lintang%d", i), pages[i].GetString(0))
+			}
+
+			// read block di luar file
+			err = dm.ReadAsync(NewBlockID("test.db", 100), NewPage(4096)).Wait()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAsyncReadChecksumMismatch(t *testing.T) {
+	os.RemoveAll("lintangdb_aio")
+	defer os.RemoveAll("lintangdb_aio")
+
+	dm, err := NewDiskManager("lintangdb_aio", 4096)
+	assert.NoError(t, err)
+	defer dm.Close()
+
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 0), page))
+
+	f, err := os.OpenFile(filepath.Join("lintangdb_aio", "test.db"), os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, 100)
+	assert.NoError(t, err)
+	f.Close()
+
+	err = dm.ReadAsync(NewBlockID("test.db", 0), NewPage(4096)).Wait()
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestUringShortReadAndClose(t *testing.T) {
+	engine, err := newUringEngine()
+	if err != nil {
+		t.Skipf("io_uring not available: %s", err)
+	}
+	f, err := NewOSFS().OpenFile(filepath.Join(t.TempDir(), "test.db"), os.O_CREATE|os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	defer f.Close()
+	data := bytes.Repeat([]byte("lintang"), 1000)
+	result := make(chan error, 1)
+	engine.submit(f, data, 0, true, func(n int, err error) {
+		assert.Equal(t, len(data), n)
+		result <- err
+	})
+	assert.NoError(t, <-result)
+
+	// read melewati akhir file: byte yang ada tetap terbaca, lalu io.EOF
+	buf := make([]byte, 8192)
+	engine.submit(f, buf, 0, false, func(n int, err error) {
+		assert.Equal(t, len(data), n)
+		result <- err
+	})
+	assert.ErrorIs(t, <-result, io.EOF)
+	assert.Equal(t, data, buf[:len(data)])
+
+	// close sambil banyak submit menunggu slot inflight: semua submit selesai (sukses / errIOEngineClosed), tidak ada yang hang
+	var wg sync.WaitGroup
+	for i := 0; i < 4*uringEntries; i++ {
+		wg.Add(1)
+		go engine.submit(f, make([]byte, 4096), 0, false, func(n int, err error) {
+			defer wg.Done()
+			if err != nil && err != io.EOF {
+				assert.ErrorIs(t, err, errIOEngineClosed)
+			}
+		})
+	}
+	closed := make(chan struct{})
+	go func() {
+		assert.NoError(t, engine.close())
+		wg.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("io_uring engine close hangs")
+	}
+}
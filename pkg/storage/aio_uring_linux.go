@@ -0,0 +1,369 @@
+//go:build linux
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysIOUringSetup = 425
+	sysIOUringEnter = 426
+
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringOpNop   = 0
+	ioringOpRead  = 22
+	ioringOpWrite = 23
+
+	ioringEnterGetEvents = 1
+
+	uringEntries       = 128
+	uringCloseUserData = ^uint64(0) // user data NOP buat stop goroutine reaper
+)
+
+// layout struct di bawah sama dengan struct io_uring di <linux/io_uring.h>.
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSQOffsets
+	cqOff                                                                  uringCQOffsets
+}
+
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uringEngine. async I/O pakai io_uring. submit dari goroutine manapun, completion diproses goroutine reaper.
+type uringEngine struct {
+	fd     int
+	sqRing []byte
+	cqRing []byte
+	sqeMem []byte
+
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []uringSQE
+
+	cqHead *uint32
+	cqTail *uint32
+	cqMask uint32
+	cqes   []uringCQE
+
+	mu       sync.Mutex
+	nextID   uint64
+	pending  map[uint64]*uringOp
+	inflight chan struct{} // batasi jumlah I/O in-flight biar completion queue tidak overflow
+	closed   bool
+	closing  chan struct{} // diclose pas engine di close, buat unblock submit yang menunggu slot inflight
+	reaped   chan struct{}
+	fallback *poolEngine // buat File yang bukan file OS (tidak punya fd)
+}
+
+// uringOp. I/O yang sedang berjalan di io_uring. buf disimpan biar tidak di garbage collect selama kernel masih pakai.
+// kalau kernel cuma read/write sebagian buf, sisanya disubmit ulang (n = jumlah byte yang sudah selesai).
+type uringOp struct {
+	fd    int32
+	buf   []byte
+	off   int64
+	n     int
+	write bool
+	done  func(n int, err error)
+}
+
+func newUringEngine() (ioEngine, error) {
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIOUringSetup, uringEntries, uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	e := &uringEngine{
+		fd:       int(fd),
+		pending:  make(map[uint64]*uringOp),
+		inflight: make(chan struct{}, p.sqEntries),
+		closing:  make(chan struct{}),
+		reaped:   make(chan struct{}),
+		fallback: newPoolEngine(1),
+	}
+	err := e.mmapRings(&p)
+	if err != nil {
+		e.unmap()
+		syscall.Close(e.fd)
+		e.fallback.close()
+		return nil, err
+	}
+	go e.reap()
+	return e, nil
+}
+
+// mmapRings. mmap submission queue, completion queue & array SQE dari kernel.
+func (e *uringEngine) mmapRings(p *uringParams) error {
+	var err error
+	e.sqRing, err = syscall.Mmap(e.fd, ioringOffSQRing, int(p.sqOff.array+p.sqEntries*4),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap io_uring sq ring: %w", err)
+	}
+	e.cqRing, err = syscall.Mmap(e.fd, ioringOffCQRing, int(p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{}))),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap io_uring cq ring: %w", err)
+	}
+	e.sqeMem, err = syscall.Mmap(e.fd, ioringOffSQEs, int(p.sqEntries*uint32(unsafe.Sizeof(uringSQE{}))),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap io_uring sqes: %w", err)
+	}
+
+	e.sqTail = (*uint32)(unsafe.Pointer(&e.sqRing[p.sqOff.tail]))
+	e.sqMask = *(*uint32)(unsafe.Pointer(&e.sqRing[p.sqOff.ringMask]))
+	e.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&e.sqRing[p.sqOff.array])), p.sqEntries)
+	e.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&e.sqeMem[0])), p.sqEntries)
+
+	e.cqHead = (*uint32)(unsafe.Pointer(&e.cqRing[p.cqOff.head]))
+	e.cqTail = (*uint32)(unsafe.Pointer(&e.cqRing[p.cqOff.tail]))
+	e.cqMask = *(*uint32)(unsafe.Pointer(&e.cqRing[p.cqOff.ringMask]))
+	e.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&e.cqRing[p.cqOff.cqes])), p.cqEntries)
+	return nil
+}
+
+func (e *uringEngine) unmap() {
+	for _, b := range [][]byte{e.sqRing, e.cqRing, e.sqeMem} {
+		if b != nil {
+			syscall.Munmap(b)
+		}
+	}
+}
+
+func (e *uringEngine) enter(toSubmit, minComplete, flags uint32) error {
+	for {
+		_, _, errno := syscall.Syscall6(sysIOUringEnter, uintptr(e.fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
+
+// push. isi satu SQE & submit ke kernel. caller harus hold e.mu.
+func (e *uringEngine) push(sqe uringSQE) error {
+	tail := atomic.LoadUint32(e.sqTail)
+	idx := tail & e.sqMask
+	e.sqes[idx] = sqe
+	e.sqArray[idx] = idx
+	atomic.StoreUint32(e.sqTail, tail+1)
+	return e.enter(1, 0, 0)
+}
+
+func (e *uringEngine) submit(f File, buf []byte, off int64, write bool, done func(n int, err error)) {
+	osf, ok := f.(interface{ Fd() uintptr })
+	if !ok || len(buf) == 0 {
+		e.fallback.submit(f, buf, off, write, done)
+		return
+	}
+
+	select {
+	case e.inflight <- struct{}{}:
+	case <-e.closing:
+		done(0, errIOEngineClosed)
+		return
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		<-e.inflight
+		done(0, errIOEngineClosed)
+		return
+	}
+	id := e.nextID
+	e.nextID++
+	err := e.pushOp(id, &uringOp{fd: int32(osf.Fd()), buf: buf, off: off, write: write, done: done})
+	e.mu.Unlock()
+
+	if err != nil {
+		<-e.inflight
+		done(0, err)
+	}
+}
+
+// pushOp. submit sisa buf op yang belum selesai (buf[op.n:]) dengan user data id. caller harus hold e.mu.
+func (e *uringEngine) pushOp(id uint64, op *uringOp) error {
+	e.pending[id] = op
+	sqe := uringSQE{
+		opcode:   ioringOpRead,
+		fd:       op.fd,
+		off:      uint64(op.off) + uint64(op.n),
+		addr:     uint64(uintptr(unsafe.Pointer(&op.buf[op.n]))),
+		len:      uint32(len(op.buf) - op.n),
+		userData: id,
+	}
+	if op.write {
+		sqe.opcode = ioringOpWrite
+	}
+	err := e.push(sqe)
+	if err != nil {
+		delete(e.pending, id)
+		return fmt.Errorf("io_uring_enter: %w", err)
+	}
+	return nil
+}
+
+// reap. goroutine yang menunggu completion dari kernel & panggil callback done tiap I/O.
+func (e *uringEngine) reap() {
+	defer close(e.reaped)
+	for {
+		err := e.enter(0, 1, ioringEnterGetEvents)
+		if err != nil {
+			e.failPending(fmt.Errorf("io_uring_enter: %w", err))
+			return
+		}
+
+		stop := false
+		for {
+			head := atomic.LoadUint32(e.cqHead)
+			if head == atomic.LoadUint32(e.cqTail) {
+				break
+			}
+			cqe := e.cqes[head&e.cqMask]
+			atomic.StoreUint32(e.cqHead, head+1)
+
+			if cqe.userData == uringCloseUserData {
+				stop = true
+				continue
+			}
+			e.mu.Lock()
+			op := e.pending[cqe.userData]
+			delete(e.pending, cqe.userData)
+			if op == nil {
+				e.mu.Unlock()
+				continue
+			}
+			n, err := uringResult(op, cqe.res)
+			if err == nil && n < len(op.buf) {
+				// kernel cuma read/write sebagian: submit ulang sisanya, slot inflight tetap dipakai op
+				err = e.pushOp(cqe.userData, op)
+				if err == nil {
+					e.mu.Unlock()
+					continue
+				}
+			}
+			e.mu.Unlock()
+			<-e.inflight
+			op.done(n, err)
+		}
+		if stop {
+			return
+		}
+	}
+}
+
+/*
+uringResult. tambah hasil cqe ke op.n & return (n, err) dengan semantik yang sama dengan ReadAt/WriteAt.
+err nil & n < len(op.buf) artinya I/O baru selesai sebagian & sisanya harus disubmit ulang.
+read 0 byte artinya sudah di akhir file (io.EOF), write 0 byte artinya write tidak bisa lanjut (io.ErrShortWrite).
+*/
+func uringResult(op *uringOp, res int32) (int, error) {
+	if res < 0 {
+		if syscall.Errno(-res) == syscall.EINTR || syscall.Errno(-res) == syscall.EAGAIN {
+			return op.n, nil // submit ulang
+		}
+		return op.n, syscall.Errno(-res)
+	}
+	if res == 0 {
+		if op.write {
+			return op.n, io.ErrShortWrite
+		}
+		return op.n, io.EOF
+	}
+	op.n += int(res)
+	return op.n, nil
+}
+
+// failPending. callback semua I/O yang masih pending dengan error (reaper berhenti karena io_uring_enter error).
+func (e *uringEngine) failPending(err error) {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = make(map[uint64]*uringOp)
+	e.markClosed()
+	e.mu.Unlock()
+	for _, op := range pending {
+		<-e.inflight
+		op.done(0, err)
+	}
+}
+
+// markClosed. tolak submit baru & unblock submit yang menunggu slot inflight. caller harus hold e.mu.
+func (e *uringEngine) markClosed() {
+	if !e.closed {
+		e.closed = true
+		close(e.closing)
+	}
+}
+
+func (e *uringEngine) close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.markClosed()
+	e.mu.Unlock()
+
+	// tunggu semua I/O in-flight selesai (kernel tidak pakai buffer lagi), baru stop reaper
+	for i := 0; i < cap(e.inflight); i++ {
+		select {
+		case e.inflight <- struct{}{}:
+		case <-e.reaped:
+		}
+	}
+	e.mu.Lock()
+	err := e.push(uringSQE{opcode: ioringOpNop, userData: uringCloseUserData})
+	e.mu.Unlock()
+	if err == nil {
+		<-e.reaped
+	}
+
+	e.unmap()
+	e.fallback.close()
+	return syscall.Close(e.fd)
+}
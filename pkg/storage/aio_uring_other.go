@@ -0,0 +1,10 @@
+//go:build !linux
+
+package storage
+
+import "errors"
+
+// newUringEngine. io_uring cuma ada di linux, IOEngineAuto fallback ke thread pool.
+func newUringEngine() (ioEngine, error) {
+	return nil, errors.New("io_uring is not supported on this platform")
+}
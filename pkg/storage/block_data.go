@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+block data. transformasi isi block di antara page & slot block nya di file (offset blockNum * blockSize):
+page dicompress dulu (kalau file dicompress), lalu dienkripsi (kalau file dienkripsi). read kebalikannya.
+*/
+
+// blockTransforms. return codec & data key file filename (nil kalau file tidak dicompress / dienkripsi).
+func (dm *DiskManager) blockTransforms(filename string) (Codec, *fileKeys, error) {
+	codec, err := dm.fileCodec(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := dm.fileKeySet(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return codec, keys, nil
+}
+
+// isTransformedFile. return true kalau isi block file filename di disk beda dengan isi page (dicompress / dienkripsi).
+func (dm *DiskManager) isTransformedFile(filename string) (bool, error) {
+	codec, keys, err := dm.blockTransforms(filename)
+	return codec != nil || keys != nil, err
+}
+
+// writeBlockData. write isi block ke slot nya di file. block file yang dicompress diwrite compressed & sisa slot di punch hole.
+func (dm *DiskManager) writeBlockData(df *diskFile, blockID BlockID, contents []byte) error {
+	blockSize := dm.FileBlockSize(blockID.GetFilename())
+	off := int64(blockID.GetBlockNum() * blockSize)
+	codec, keys, err := dm.blockTransforms(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+
+	data := contents
+	compressedLength := 0 // 0 = block disimpan tanpa compression
+	if codec != nil {
+		compressed, err := codec.Compress(contents)
+		if err != nil {
+			return err
+		}
+		if len(compressed) < blockSize {
+			// page yang tidak bisa dicompress disimpan apa adanya
+			data = compressed
+			compressedLength = len(compressed)
+		}
+	}
+	var entry []byte
+	if keys != nil {
+		data, entry, err = keys.encryptBlock(blockID, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = df.writeAt(data, off)
+	if err != nil {
+		return err
+	}
+	if compressedLength > 0 {
+		err = dm.punchBlockTail(df, off, blockSize, compressedLength)
+		if err != nil {
+			return err
+		}
+	}
+	if keys != nil {
+		err = dm.writeEncryptionEntry(blockID, entry)
+		if err != nil {
+			return err
+		}
+	}
+	if codec != nil {
+		return dm.writeCompressedLength(blockID, compressedLength)
+	}
+	return nil
+}
+
+// punchBlockTail. perbesar file sampai akhir slot block (blockSize bytes) di offset off (kalau perlu) & punch hole sisa slot setelah length bytes data compressed.
+func (dm *DiskManager) punchBlockTail(df *diskFile, off int64, blockSize, length int) error {
+	end := off + int64(blockSize)
+	size, err := df.f.Size()
+	if err != nil {
+		return err
+	}
+	if size < end {
+		// perbesar file sampai akhir slot (WriteAt tidak pernah memperkecil file kalau ada write block lain bersamaan)
+		_, err = df.writeAt([]byte{0}, end-1)
+		if err != nil {
+			return err
+		}
+	}
+	holeStart := off + (int64(length)+holeAlignment-1)/holeAlignment*holeAlignment
+	if p, ok := df.f.(holePuncher); ok && holeStart < end {
+		return p.PunchHole(holeStart, end-holeStart)
+	}
+	return nil
+}
+
+// readBlockData. read isi block dari slot nya di file ke contents (decrypt & decompress kalau perlu).
+func (dm *DiskManager) readBlockData(df *diskFile, blockID BlockID, contents []byte) error {
+	blockSize := dm.FileBlockSize(blockID.GetFilename())
+	off := int64(blockID.GetBlockNum() * blockSize)
+	codec, keys, err := dm.blockTransforms(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+
+	compressedLength := 0
+	if codec != nil {
+		compressedLength, err = dm.readCompressedLength(blockID)
+		if err != nil {
+			return err
+		}
+		if compressedLength >= blockSize {
+			return fmt.Errorf("%w: file %s block %d has length %d", ErrCorruptCompressedBlock, blockID.GetFilename(), blockID.GetBlockNum(), compressedLength)
+		}
+	}
+	data := contents
+	if compressedLength > 0 {
+		data = make([]byte, compressedLength)
+	}
+
+	var n int
+	if m := df.getMmap(); m != nil {
+		n, err = m.readAt(data, off) // copy dari memory mapping
+	} else {
+		n, err = df.readAt(data, off)
+	}
+	if err == io.EOF && n > 0 {
+		// block terakhir file tidak penuh, sisanya diisi 0
+		clear(data[n:])
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if keys != nil {
+		entry, err := dm.readEncryptionEntry(blockID)
+		if err != nil {
+			return err
+		}
+		err = keys.decryptBlock(blockID, data, entry)
+		if err != nil {
+			return err
+		}
+	}
+	if compressedLength > 0 {
+		err = codec.Decompress(contents, data)
+		if err != nil {
+			return fmt.Errorf("%w: file %s block %d: %v", ErrCorruptCompressedBlock, blockID.GetFilename(), blockID.GetBlockNum(), err)
+		}
+	}
+	return nil
+}
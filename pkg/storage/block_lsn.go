@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"encoding/binary"
+)
+
+/*
+block LSN. selama LSN source dipasang (SetLSNSource, otomatis oleh log.NewLogManager), setiap write block mencatat LSN log terakhir pas block diwrite
+di file pendamping <filename>.lsn (8 byte per block). block yang diwrite setelah LSN x punya block LSN >= x, dipakai incremental backup
+buat cari block yang berubah sejak backup sebelumnya. block yang belum pernah diwrite selama LSN source dipasang punya block LSN 0.
+*/
+const (
+	blockLSNSize    = 8
+	blockLSNFileExt = ".lsn"
+)
+
+// LSNSource. sumber LSN buat block LSN. BlockLSN return LSN < 0 kalau block LSN file blockID tidak dicatat.
+type LSNSource interface {
+	BlockLSN(blockID BlockID) int
+}
+
+// SetLSNSource. pasang sumber LSN buat block LSN semua write block berikutnya. nil buat berhenti mencatat block LSN.
+func (dm *DiskManager) SetLSNSource(src LSNSource) {
+	if src == nil {
+		dm.lsnSource.Store(nil)
+		return
+	}
+	dm.lsnSource.Store(&src)
+}
+
+// blockLSN. return block LSN buat write block blockID, -1 kalau tidak dicatat. caller harus hold latch block.
+func (dm *DiskManager) blockLSN(blockID BlockID) int {
+	src := dm.lsnSource.Load()
+	if src == nil {
+		return -1
+	}
+	return (*src).BlockLSN(blockID)
+}
+
+// writeBlockLSN. write block LSN ke file .lsn di offset blockNum * blockLSNSize.
+func (dm *DiskManager) writeBlockLSN(blockID BlockID, lsn int) error {
+	df, err := dm.acquireFile(blockID.GetFilename() + blockLSNFileExt)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	var buf [blockLSNSize]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(lsn))
+	_, err = df.writeAt(buf[:], int64(blockID.GetBlockNum()*blockLSNSize))
+	return err
+}
+
+// ReadBlockLSN. return block LSN blockID (LSN log terakhir pas block diwrite). return 0 kalau block LSN belum pernah dicatat.
+func (dm *DiskManager) ReadBlockLSN(blockID BlockID) (int, error) {
+	df, err := dm.acquireFile(blockID.GetFilename() + blockLSNFileExt)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.releaseFile(df)
+	var buf [blockLSNSize]byte
+	n, err := df.f.ReadAt(buf[:], int64(blockID.GetBlockNum()*blockLSNSize))
+	if n < blockLSNSize {
+		// block LSN belum ada di file .lsn
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint64(buf[:])), nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lsnCounter. LSN source dengan LSN yang diset manual.
+type lsnCounter struct {
+	lsn int
+}
+
+func (c *lsnCounter) BlockLSN(blockID BlockID) int {
+	return c.lsn
+}
+
+func TestBlockLSN(t *testing.T) {
+	dm, err := NewDiskManager("lintangdb_lsn", 4096, WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 0), page))
+
+	src := &lsnCounter{lsn: 5}
+	dm.SetLSNSource(src)
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 1), page))
+	src.lsn = 9
+	assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: NewBlockID("test.db", 3), Page: page}}))
+	dm.SetLSNSource(nil)
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 2), page))
+
+	for blockNum, want := range []int{0, 5, 0, 9, 0} {
+		lsn, err := dm.ReadBlockLSN(NewBlockID("test.db", blockNum))
+		assert.NoError(t, err)
+		assert.Equal(t, want, lsn, "block %d", blockNum)
+	}
+	assert.NotContains(t, dm.Files(), "test.db"+blockLSNFileExt)
+}
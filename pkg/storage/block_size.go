@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/lintang-b-s/go-simpledb/pkg"
+)
+
+/*
+block size. block size database ditentukan pas database dibuat (NewDiskManager) & dicatat di manifest. tiap file bisa punya block size sendiri
+(mis. block besar buat file heap, block kecil buat file index), ditentukan pas file dibuat (WithFileBlockSize / CreateFile) & dicatat di manifest.
+block size harus kelipatan 2 antara pkg.MIN_PAGE_SIZE & pkg.MAX_PAGE_SIZE.
+*/
+
+var ErrInvalidBlockSize = errors.New("invalid block size")
+
+// validateBlockSize. return error kalau blockSize bukan kelipatan 2 antara MIN_PAGE_SIZE & MAX_PAGE_SIZE.
+func validateBlockSize(blockSize int) error {
+	if blockSize < pkg.MIN_PAGE_SIZE || blockSize > pkg.MAX_PAGE_SIZE || bits.OnesCount(uint(blockSize)) != 1 {
+		return fmt.Errorf("%w: %d (must be a power of two between %d and %d)", ErrInvalidBlockSize, blockSize, pkg.MIN_PAGE_SIZE, pkg.MAX_PAGE_SIZE)
+	}
+	return nil
+}
+
+// WithFileBlockSize. file-file ini dibuat dengan block size blockSize (bukan block size database). file yang sudah ada tetap pakai block size yang tercatat di manifest.
+func WithFileBlockSize(blockSize int, filenames ...string) DiskManagerOption {
+	return func(dm *DiskManager) {
+		for _, filename := range filenames {
+			dm.fileBlockSizes[filename] = blockSize
+		}
+	}
+}
+
+// FileBlockSize. return block size file filename: block size yang tercatat di manifest, yang dikonfigurasi WithFileBlockSize, atau block size database.
+func (dm *DiskManager) FileBlockSize(filename string) int {
+	if m := dm.manifest; m != nil {
+		m.mu.Lock()
+		entry, ok := m.files[filename]
+		m.mu.Unlock()
+		if ok {
+			return entry.blockSize
+		}
+	}
+	return dm.configuredBlockSize(filename)
+}
+
+// configuredBlockSize. block size buat file baru filename.
+func (dm *DiskManager) configuredBlockSize(filename string) int {
+	if blockSize, ok := dm.fileBlockSizes[filename]; ok {
+		return blockSize
+	}
+	return dm.blockSize
+}
+
+// CreateFile. buat file filename dengan block size blockSize & catat di manifest. return error kalau file sudah ada dengan block size lain.
+func (dm *DiskManager) CreateFile(filename string, blockSize int) error {
+	err := validateBlockSize(blockSize)
+	if err != nil {
+		return err
+	}
+	return dm.createFile(filename, fileEntry{blockSize: blockSize})
+}
+
+// createFile. catat file filename di manifest (lihat registerFileEntry) & buat file nya.
+func (dm *DiskManager) createFile(filename string, entry fileEntry) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot create %s", ErrReadOnly, filename)
+	}
+	err := dm.registerFileEntry(filename, entry)
+	if err != nil {
+		return err
+	}
+	df, err := dm.acquireFile(filename)
+	if err != nil {
+		return err
+	}
+	dm.releaseFile(df)
+	return nil
+}
+
+// checkPageSize. return error kalau ukuran page beda dengan block size file blockID.
+func (dm *DiskManager) checkPageSize(blockID BlockID, page *Page) error {
+	blockSize := dm.FileBlockSize(blockID.GetFilename())
+	if len(page.Contents()) != blockSize {
+		return fmt.Errorf("%w: page of %d bytes for file %s with block size %d", ErrInvalidBlockSize, len(page.Contents()), blockID.GetFilename(), blockSize)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lintang-b-s/go-simpledb/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidBlockSize(t *testing.T) {
+	for _, blockSize := range []int{1024, 5000, 131072} {
+		_, err := NewDiskManager("lintangdb_bs_invalid", blockSize, WithVFS(NewMemFS()))
+		assert.ErrorIs(t, err, ErrInvalidBlockSize, "block size %d", blockSize)
+	}
+	_, err := NewDiskManager("lintangdb_bs_invalid", 4096, WithVFS(NewMemFS()), WithFileBlockSize(3000, "index.db"))
+	assert.ErrorIs(t, err, ErrInvalidBlockSize)
+
+	// blockSize 0: database baru pakai block size default
+	dm, err := NewDiskManager("lintangdb_bs_invalid", 0, WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+	assert.Equal(t, pkg.DEFAULT_PAGE_SIZE, dm.BlockSize())
+}
+
+func TestFileBlockSize(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_bs", 16384, WithVFS(fs), WithFileBlockSize(8192, "index.db"))
+	assert.NoError(t, err)
+	assert.Equal(t, 16384, dm.FileBlockSize("table.db"))
+	assert.Equal(t, 8192, dm.FileBlockSize("index.db"))
+	assert.NoError(t, dm.CreateFile("log.db", 4096))
+
+	for _, file := range []struct {
+		name      string
+		blockSize int
+	}{{"table.db", 16384}, {"index.db", 8192}, {"log.db", 4096}} {
+		for i := 0; i < 3; i++ {
+			blockID, err := dm.Append(file.name)
+			assert.NoError(t, err)
+			page := NewPage(file.blockSize)
+			page.PutString(0, file.name)
+			page.PutInt(file.blockSize-4, i)
+			assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: blockID, Page: page}}))
+		}
+		n, err := dm.BlockLength(file.name)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		// page dengan ukuran beda dengan block size file ditolak
+		err = dm.Write(NewBlockID(file.name, 0), NewPage(2*file.blockSize))
+		assert.ErrorIs(t, err, ErrInvalidBlockSize)
+	}
+	assert.ErrorIs(t, dm.CreateFile("index.db", 4096), ErrBlockSizeMismatch)
+	assert.NoError(t, dm.Close())
+
+	// reopen dengan blockSize 0: block size database & file dibaca dari manifest
+	dm, err = NewDiskManager("lintangdb_bs", 0, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, 16384, dm.BlockSize())
+	assert.Equal(t, 8192, dm.FileBlockSize("index.db"))
+	assert.Equal(t, 4096, dm.FileBlockSize("log.db"))
+	for _, file := range []struct {
+		name      string
+		blockSize int
+	}{{"table.db", 16384}, {"index.db", 8192}, {"log.db", 4096}} {
+		for i := 0; i < 3; i++ {
+			page := NewPage(file.blockSize)
+			assert.NoError(t, dm.Read(NewBlockID(file.name, i), page))
+			assert.Equal(t, file.name, page.GetString(0))
+			assert.Equal(t, i, page.GetInt(file.blockSize-4))
+		}
+	}
+}
+
+func TestDoubleWriteMixedBlockSize(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_bs_dw", 16384, WithVFS(fs), WithFileBlockSize(4096, "index.db"))
+	assert.NoError(t, err)
+
+	writes := []BlockWrite{
+		{BlockID: NewBlockID("table.db", 0), Page: NewPage(16384)},
+		{BlockID: NewBlockID("index.db", 0), Page: NewPage(4096)},
+	}
+	writes[0].Page.PutString(0, "lintang")
+	writes[1].Page.PutString(0, "birda")
+	assert.NoError(t, dm.WriteBlocks(writes))
+
+	// simulasi crash: block baru sudah masuk file doublewrite tapi belum ditulis ke lokasi aslinya
+	writes[0].Page.PutString(0, "saputra")
+	writes[1].Page.PutString(0, "index")
+	var buf []byte
+	for _, w := range writes {
+		slot := make([]byte, doubleWriteHeaderSize+len(w.Page.Contents()))
+		assert.NoError(t, dm.encodeDoubleWriteSlot(slot, w))
+		buf = append(buf, slot...)
+	}
+	assert.NoError(t, dm.Close())
+	f, err := fs.OpenFile(dm.filePath(doubleWriteFile), os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt(buf, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	dm, err = NewDiskManager("lintangdb_bs_dw", 16384, WithVFS(fs))
+	assert.NoError(t, err)
+	page := NewPage(16384)
+	assert.NoError(t, dm.Read(NewBlockID("table.db", 0), page))
+	assert.Equal(t, "saputra", page.GetString(0))
+	page = NewPage(4096)
+	assert.NoError(t, dm.Read(NewBlockID("index.db", 0), page))
+	assert.Equal(t, "index", page.GetString(0))
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ErrChecksumMismatch. dikembalikan Read kalau checksum block di disk beda dengan checksum yang disimpan pas Write (block corrupt).
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+const (
+	checksumSize    = 4      // ukuran checksum (crc32c) per block
+	checksumFileExt = ".crc" // checksum tiap block file disimpan di file terpisah: <filename>.crc
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// pageChecksum. hitung crc32c dari isi block.
+func pageChecksum(b []byte) uint32 {
+	return crc32.Checksum(b, crc32cTable)
+}
+
+// isZeroBlock. return true kalau semua byte di block = 0 (block hasil Append / belum pernah diwrite).
+func isZeroBlock(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeChecksum. write checksum block ke file checksum di offset blockNum * checksumSize.
+func (dm *DiskManager) writeChecksum(blockID BlockID, contents []byte) error {
+	df, err := dm.acquireFile(blockID.GetFilename() + checksumFileExt)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	var buf [checksumSize]byte
+	binary.LittleEndian.PutUint32(buf[:], pageChecksum(contents))
+	_, err = df.writeAt(buf[:], int64(blockID.GetBlockNum()*checksumSize))
+	return err
+}
+
+// readChecksum. read checksum block dari file checksum. return 0 kalau checksum block belum pernah ditulis.
+func (dm *DiskManager) readChecksum(blockID BlockID) (uint32, error) {
+	df, err := dm.acquireFile(blockID.GetFilename() + checksumFileExt)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.releaseFile(df)
+	var buf [checksumSize]byte
+	n, err := df.f.ReadAt(buf[:], int64(blockID.GetBlockNum()*checksumSize))
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n < checksumSize {
+		// checksum block belum ada di file checksum
+		return 0, nil
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// validateChecksum. bandingkan checksum isi block yang diread dari disk dengan checksum yang disimpan pas Write.
+func (dm *DiskManager) validateChecksum(blockID BlockID, contents []byte) error {
+	stored, err := dm.readChecksum(blockID)
+	if err != nil {
+		return err
+	}
+	if stored == 0 && isZeroBlock(contents) {
+		// block kosong yang belum pernah diwrite
+		return nil
+	}
+	if stored != pageChecksum(contents) {
+		return fmt.Errorf("%w: file %s block %d", ErrChecksumMismatch, blockID.GetFilename(), blockID.GetBlockNum())
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"sync"
+)
+
+/*
+page compression per file. block dicompress pas Write & ditulis di awal slot block nya (offset blockNum * blockSize), sisa slot di punch hole (sparse file)
+kalau file system support. panjang data compressed tiap block disimpan di file <filename>.cmp: header (magic & codec id) lalu 4 bytes per block,
+panjang 0 artinya block disimpan tanpa compression (mis. page yang tidak bisa dicompress). codec dicatat di header, jadi file tetap bisa diread
+walaupun DiskManager dibuka tanpa WithCompression.
+*/
+const (
+	compressionFileExt    = ".cmp"
+	compressionMagic      = 0x31504d43 // "CMP1"
+	compressionHeaderSize = 16
+	compressedLengthSize  = 4
+	holeAlignment         = 4096 // granularity punch hole file system
+)
+
+var (
+	ErrUnknownCodec           = errors.New("unknown compression codec")
+	ErrCorruptCompressedBlock = errors.New("corrupt compressed block")
+	ErrCompressionBlockSize   = errors.New("block size too small for compression")
+)
+
+// Codec. algoritma compression page. dst di Decompress ukurannya sama dengan page asli.
+type Codec interface {
+	ID() byte
+	Name() string
+	Compress(src []byte) ([]byte, error)
+	Decompress(dst, src []byte) error
+}
+
+var (
+	codecsLatch sync.RWMutex
+	codecs      = map[byte]Codec{}
+)
+
+// RegisterCodec. daftarkan codec biar file yang dicompress dengan codec tsb bisa dibuka. id 0 tidak boleh dipakai.
+func RegisterCodec(codec Codec) {
+	codecsLatch.Lock()
+	defer codecsLatch.Unlock()
+	codecs[codec.ID()] = codec
+}
+
+func lookupCodec(id byte) (Codec, error) {
+	codecsLatch.RLock()
+	defer codecsLatch.RUnlock()
+	codec, ok := codecs[id]
+	if !ok || id == 0 {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCodec, id)
+	}
+	return codec, nil
+}
+
+func init() {
+	RegisterCodec(FlateCodec{})
+}
+
+// FlateCodec. codec DEFLATE (compress/flate) dengan level BestSpeed.
+type FlateCodec struct{}
+
+var flateWriters = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+func (FlateCodec) ID() byte { return 1 }
+
+func (FlateCodec) Name() string { return "flate" }
+
+func (FlateCodec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	_, err := w.Write(src)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (FlateCodec) Decompress(dst, src []byte) error {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	_, err := io.ReadFull(r, dst)
+	return err
+}
+
+// WithCompression. file-file ini dicompress dengan codec. cuma berlaku buat file yang baru dibuat (file kosong), file lama yang tidak dicompress tetap tidak dicompress.
+// block size file harus lebih besar dari holeAlignment: sisa slot block setelah data compressed cuma bisa di punch hole per holeAlignment bytes.
+func WithCompression(codec Codec, filenames ...string) DiskManagerOption {
+	return func(dm *DiskManager) {
+		RegisterCodec(codec)
+		for _, filename := range filenames {
+			dm.compression[filename] = codec
+		}
+	}
+}
+
+// holePuncher. File yang bisa dealokasi range byte tanpa mengubah ukuran file (fallocate PUNCH_HOLE).
+type holePuncher interface {
+	PunchHole(off, size int64) error
+}
+
+// isCompressedFile. return true kalau file filename dicompress (dikonfigurasi atau punya file .cmp). tidak buka file lewat file cache.
+func (dm *DiskManager) isCompressedFile(filename string) bool {
+	if dm.compression[filename] != nil {
+		return true
+	}
+	_, err := dm.fs.Stat(dm.filePath(filename + compressionFileExt))
+	return err == nil
+}
+
+// fileCodec. return codec file filename, nil kalau file tidak dicompress. header file .cmp dibuat kalau file baru & dikonfigurasi WithCompression.
+func (dm *DiskManager) fileCodec(filename string) (Codec, error) {
+	dm.codecLatch.Lock()
+	defer dm.codecLatch.Unlock()
+	codec, ok := dm.fileCodecs[filename]
+	if ok {
+		return codec, nil
+	}
+	if !isBlockFile(filename) {
+		dm.fileCodecs[filename] = nil
+		return nil, nil
+	}
+
+	forkName := filename + compressionFileExt
+	_, err := dm.fs.Stat(dm.filePath(forkName))
+	switch {
+	case err == nil:
+		codec, err = dm.readCompressionHeader(forkName)
+		if err != nil {
+			return nil, err
+		}
+	case errors.Is(err, fs.ErrNotExist) && dm.compression[filename] != nil:
+		info, err := dm.fs.Stat(dm.filePath(filename))
+		if err == nil && info.Size() > 0 {
+			break // file lama tanpa compression
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		err = dm.checkCompressionBlockSize(filename)
+		if err != nil {
+			return nil, err
+		}
+		codec = dm.compression[filename]
+		err = dm.writeCompressionHeader(forkName, codec)
+		if err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	dm.fileCodecs[filename] = codec
+	return codec, nil
+}
+
+/*
+checkCompressionBlockSize. return ErrCompressionBlockSize kalau block size file filename tidak lebih besar dari holeAlignment.
+slot block dengan block size <= holeAlignment tidak pernah punya sisa yang bisa di punch hole, jadi compression tidak menghemat space di disk.
+*/
+func (dm *DiskManager) checkCompressionBlockSize(filename string) error {
+	blockSize := dm.FileBlockSize(filename)
+	if blockSize <= holeAlignment {
+		return fmt.Errorf("%w: %s has block size %d, must be larger than %d", ErrCompressionBlockSize, filename, blockSize, holeAlignment)
+	}
+	return nil
+}
+
+// checkNewCompressedFiles. cek block size file yang dikonfigurasi WithCompression & belum ada di database (database read-only tidak membuat file baru).
+func (dm *DiskManager) checkNewCompressedFiles() error {
+	if dm.readOnly {
+		return nil
+	}
+	files := dm.Files()
+	for filename := range dm.compression {
+		if slices.Contains(files, filename) {
+			continue
+		}
+		err := dm.checkCompressionBlockSize(filename)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dm *DiskManager) readCompressionHeader(forkName string) (Codec, error) {
+	df, err := dm.acquireFile(forkName)
+	if err != nil {
+		return nil, err
+	}
+	defer dm.releaseFile(df)
+	var header [compressionHeaderSize]byte
+	_, err = df.f.ReadAt(header[:], 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compression header of %s: %w", forkName, err)
+	}
+	if binary.LittleEndian.Uint32(header[0:]) != compressionMagic {
+		return nil, fmt.Errorf("%w: invalid compression header in %s", ErrCorruptCompressedBlock, forkName)
+	}
+	return lookupCodec(header[4])
+}
+
+func (dm *DiskManager) writeCompressionHeader(forkName string, codec Codec) error {
+	df, err := dm.acquireFile(forkName)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	var header [compressionHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:], compressionMagic)
+	header[4] = codec.ID()
+	_, err = df.writeAt(header[:], 0)
+	if err != nil {
+		return err
+	}
+	return df.sync()
+}
+
+// readCompressedLength. return panjang data compressed block, 0 kalau block tidak dicompress / belum pernah ditulis.
+func (dm *DiskManager) readCompressedLength(blockID BlockID) (int, error) {
+	df, err := dm.acquireFile(blockID.GetFilename() + compressionFileExt)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.releaseFile(df)
+	var buf [compressedLengthSize]byte
+	n, err := df.f.ReadAt(buf[:], int64(compressionHeaderSize+blockID.GetBlockNum()*compressedLengthSize))
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n < compressedLengthSize {
+		// panjang block belum ada di file .cmp
+		return 0, nil
+	}
+	return int(binary.LittleEndian.Uint32(buf[:])), nil
+}
+
+func (dm *DiskManager) writeCompressedLength(blockID BlockID, length int) error {
+	df, err := dm.acquireFile(blockID.GetFilename() + compressionFileExt)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	var buf [compressedLengthSize]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(length))
+	_, err = df.writeAt(buf[:], int64(compressionHeaderSize+blockID.GetBlockNum()*compressedLengthSize))
+	return err
+}
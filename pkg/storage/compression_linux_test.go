@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionPunchHole(t *testing.T) {
+	for _, tc := range []struct {
+		blockSize    int
+		maxAllocated int64 // byte teralokasi maksimal (file tanpa compression 16 block)
+	}{{65536, 16 * 65536 / 4}, {8192, 16 * 8192 * 3 / 4}} {
+		t.Run(fmt.Sprintf("block size %d", tc.blockSize), func(t *testing.T) {
+			defer os.RemoveAll("lintangdb_cmp_hole")
+			dm, err := NewDiskManager("lintangdb_cmp_hole", tc.blockSize, WithCompression(FlateCodec{}, "table.db"))
+			assert.NoError(t, err)
+			defer dm.Close()
+
+			page := NewPage(tc.blockSize)
+			page.PutString(0, strings.Repeat("lintang", tc.blockSize/64))
+			for i := 0; i < 16; i++ {
+				assert.NoError(t, dm.Write(NewBlockID("table.db", i), page))
+			}
+			assert.NoError(t, dm.SyncFile("table.db"))
+
+			info, err := os.Stat(filepath.Join("lintangdb_cmp_hole", "table.db"))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(16*tc.blockSize), info.Size())
+			allocated := info.Sys().(*syscall.Stat_t).Blocks * 512
+			if allocated >= info.Size() {
+				t.Skip("file system does not support punching holes")
+			}
+			assert.LessOrEqual(t, allocated, tc.maxAllocated)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	defer os.RemoveAll("lintangdb_cmp")
+	dm, err := NewDiskManager("lintangdb_cmp", 16384, WithCompression(FlateCodec{}, "table.db"))
+	assert.NoError(t, err)
+
+	random := make([]byte, 16384)
+	_, err = rand.Read(random)
+	assert.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		blockID, err := dm.Append("table.db")
+		assert.NoError(t, err)
+		page := NewPage(16384)
+		if i == 3 {
+			page = NewPageFromByteSlice(random) // page yang tidak bisa dicompress disimpan apa adanya
+		} else {
+			page.PutString(0, strings.Repeat(fmt.Sprintf("lintang%d", i), 100))
+		}
+		assert.NoError(t, dm.Write(blockID, page))
+	}
+	assert.NoError(t, dm.SyncFile("table.db"))
+
+	length, err := dm.BlockLength("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, length)
+
+	checkPages := func(dm *DiskManager) {
+		for i := 0; i < 8; i++ {
+			page := NewPage(16384)
+			if i%2 == 0 {
+				assert.NoError(t, dm.ReadAsync(NewBlockID("table.db", i), page).Wait())
+			} else {
+				assert.NoError(t, dm.Read(NewBlockID("table.db", i), page))
+			}
+			if i == 3 {
+				assert.Equal(t, random, page.Contents())
+			} else {
+				assert.Equal(t, strings.Repeat(fmt.Sprintf("lintang%d", i), 100), page.GetString(0))
+			}
+		}
+	}
+	checkPages(dm)
+	assert.NoError(t, dm.Close())
+
+	// codec dicatat di file .cmp: file tetap diread dengan benar tanpa WithCompression
+	dm, err = NewDiskManager("lintangdb_cmp", 16384)
+	assert.NoError(t, err)
+	checkPages(dm)
+	codec, err := dm.fileCodec("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, "flate", codec.Name())
+	assert.NoError(t, dm.Close())
+
+	// file lama yang tidak dicompress tetap tidak dicompress
+	dm, err = NewDiskManager("lintangdb_cmp", 16384)
+	assert.NoError(t, err)
+	page := NewPage(16384)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("plain.db", 0), page))
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_cmp", 16384, WithCompression(FlateCodec{}, "plain.db"))
+	assert.NoError(t, err)
+	codec, err = dm.fileCodec("plain.db")
+	assert.NoError(t, err)
+	assert.Nil(t, codec)
+	assert.NoError(t, dm.Read(NewBlockID("plain.db", 0), page))
+	assert.Equal(t, "lintang", page.GetString(0))
+	assert.NoError(t, dm.Close())
+}
+
+func TestCompressedLengthReadError(t *testing.T) {
+	ffs := NewFaultFS(NewMemFS(), 4096, 1)
+	dm, err := NewDiskManager("lintangdb_cmp_eio", 8192, WithVFS(ffs), WithCompression(FlateCodec{}, "table.db"))
+	assert.NoError(t, err)
+	page := NewPage(8192)
+	page.PutString(0, strings.Repeat("lintang", 100))
+	assert.NoError(t, dm.Write(NewBlockID("table.db", 0), page))
+
+	// read panjang compressed gagal: error dikembalikan, block tidak dianggap disimpan tanpa compression
+	ffs.FailBlock("table.db.cmp", 0)
+	err = dm.Read(NewBlockID("table.db", 0), NewPage(8192))
+	assert.ErrorIs(t, err, syscall.EIO)
+
+	ffs.ClearFailures()
+	assert.NoError(t, dm.Read(NewBlockID("table.db", 0), page))
+	assert.Equal(t, strings.Repeat("lintang", 100), page.GetString(0))
+	assert.NoError(t, dm.Close())
+}
+
+func TestCompressionBlockSize(t *testing.T) {
+	fs := NewMemFS()
+	// slot block 4K tidak pernah punya sisa yang bisa di punch hole
+	_, err := NewDiskManager("lintangdb_cmp_4k", 4096, WithVFS(fs), WithCompression(FlateCodec{}, "table.db"))
+	assert.ErrorIs(t, err, ErrCompressionBlockSize)
+	_, err = NewDiskManager("lintangdb_cmp_16k", 16384, WithVFS(fs), WithFileBlockSize(4096, "table.db"), WithCompression(FlateCodec{}, "table.db"))
+	assert.ErrorIs(t, err, ErrCompressionBlockSize)
+
+	// file dengan block size lebih besar tetap bisa dicompress
+	dm, err := NewDiskManager("lintangdb_cmp_8k", 4096, WithVFS(fs), WithFileBlockSize(8192, "table.db"), WithCompression(FlateCodec{}, "table.db"))
+	assert.NoError(t, err)
+	page := NewPage(8192)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("table.db", 0), page))
+	_, err = fs.Stat(filepath.Join("lintangdb_cmp_8k", "table.db.cmp"))
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Close())
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+)
+
+/*
+DeleteFile. hapus file block filename beserta semua file fork nya (.crc, .fsm, .lsn, ...) & hapus file dari manifest.
+file tidak boleh sedang dipakai (return ErrFileInUse). file yang tidak ada di manifest tidak dianggap error.
+*/
+func (dm *DiskManager) DeleteFile(filename string) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot delete %s", ErrReadOnly, filename)
+	}
+	dir := filepath.Dir(dm.filePath(filename)) // directory tablespace file, di-resolve sebelum file dihapus dari manifest
+
+	err := dm.closeDeletedFile(filename)
+	if err != nil {
+		return err
+	}
+	dm.codecLatch.Lock()
+	delete(dm.fileCodecs, filename)
+	dm.codecLatch.Unlock()
+	dm.keyLatch.Lock()
+	delete(dm.fileKeySets, filename)
+	dm.keyLatch.Unlock()
+
+	// file yang tidak tercatat di manifest lagi dianggap tidak ada, jadi file yang gagal dihapus cuma sisa sampah
+	entries, err := dm.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if forkBase(entry.Name()) != filename {
+			continue
+		}
+		err = dm.fs.Remove(filepath.Join(dir, entry.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeDeletedFile. close file filename & file fork nya yang sedang dibuka, lalu hapus file & file fork nya dari manifest.
+func (dm *DiskManager) closeDeletedFile(filename string) error {
+	dm.filesLatch.Lock()
+	var files []*diskFile
+	for name, df := range dm.openFiles {
+		if forkBase(name) != filename {
+			continue
+		}
+		if df.pins > 0 {
+			dm.filesLatch.Unlock()
+			return fmt.Errorf("%w: %s", ErrFileInUse, name)
+		}
+		files = append(files, df)
+	}
+	for _, df := range files {
+		dm.detachFileLocked(df)
+	}
+	dm.filesLatch.Unlock()
+	err := dm.closeDetached(files)
+	if err != nil {
+		return err
+	}
+
+	dm.filesLatch.Lock()
+	defer dm.filesLatch.Unlock()
+
+	// file fork yang juga file block (mis. .fsm) ikut tercatat di manifest
+	m := dm.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := make(map[string]fileEntry)
+	for name, entry := range m.files {
+		if forkBase(name) == filename {
+			deleted[name] = entry
+			delete(m.files, name)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	err = dm.writeManifest(m)
+	if err != nil {
+		maps.Copy(m.files, deleted)
+	}
+	return err
+}
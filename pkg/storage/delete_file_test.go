@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteFile(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_delete", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	dm.SetLSNSource(&lsnCounter{lsn: 1})
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	for i := 0; i < 3; i++ {
+		blockID, err := dm.Allocate("test.db")
+		assert.NoError(t, err)
+		assert.NoError(t, dm.Write(blockID, page))
+		assert.NoError(t, dm.Write(NewBlockID("other.db", i), page))
+	}
+	assert.NoError(t, dm.DeleteFile("test.db"))
+	assert.Equal(t, []string{"other.db"}, dm.Files())
+	entries, err := fs.ReadDir("lintangdb_delete")
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), "test.db"), "file %s tidak dihapus", entry.Name())
+	}
+
+	// file baru dengan nama yang sama mulai kosong
+	n, err := dm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, dm.Close())
+
+	dm, err = NewDiskManager("lintangdb_delete", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other.db", "test.db"}, dm.Files())
+	assert.NoError(t, dm.Read(NewBlockID("other.db", 2), page))
+	assert.Equal(t, "lintang", page.GetString(0))
+	assert.NoError(t, dm.DeleteFile("missing.db"))
+}
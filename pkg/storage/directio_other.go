@@ -0,0 +1,6 @@
+//go:build !linux
+
+package storage
+
+// directIOFlag. O_DIRECT cuma dipakai di linux, di OS lain WithDirectIO tidak berpengaruh.
+const directIOFlag = 0
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectIO(t *testing.T) {
+	os.RemoveAll("lintangdb_direct")
+	defer os.RemoveAll("lintangdb_direct")
+
+	dm, err := NewDiskManager("lintangdb_direct", 4096, WithDirectIO(true))
+	assert.NoError(t, err)
+
+	blockID, err := dm.Append("test.db")
+	assert.NoError(t, err)
+
+	page := NewPage(4096)
+	assert.True(t, isAligned(page.Contents()))
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: blockID, Page: page}}))
+
+	pageReader := NewPage(4096)
+	assert.NoError(t, dm.Read(blockID, pageReader))
+	assert.Equal(t, "lintang", pageReader.GetString(0))
+
+	// page dengan buffer yang tidak aligned tetap bisa dipakai (lewat buffer aligned)
+	unaligned := NewPageFromByteSlice(make([]byte, 4097)[1:])
+	unaligned.PutString(0, "birda")
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 1), unaligned))
+	assert.NoError(t, dm.ReadAsync(NewBlockID("test.db", 1), unaligned).Wait())
+	assert.Equal(t, "birda", unaligned.GetString(0))
+	assert.NoError(t, dm.Read(NewBlockID("test.db", 0), unaligned))
+	assert.Equal(t, "lintang", unaligned.GetString(0))
+
+	df, err := dm.acquireFile("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, directIOFlag != 0, df.direct)
+	dm.releaseFile(df)
+
+	// file checksum & doublewrite tidak dibuka dengan O_DIRECT
+	df, err = dm.acquireFile("test.db.crc")
+	assert.NoError(t, err)
+	assert.False(t, df.direct)
+	dm.releaseFile(df)
+	assert.NoError(t, dm.Close())
+}
+
+func TestDirectIOMemFS(t *testing.T) {
+	dm, err := NewDiskManager("lintangdb_direct", 4096, WithDirectIO(true), WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("test.db", 0), page))
+	assert.NoError(t, dm.Read(NewBlockID("test.db", 0), page))
+	assert.Equal(t, "lintang", page.GetString(0))
+	assert.NoError(t, dm.Close())
+}
@@ -1,90 +1,245 @@
 package storage
 
 import (
+	"container/list"
+	"errors"
+	"maps"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
+// BlockManager. interface read/write block page yang dipakai buffer pool & log manager. diimplementasi oleh DiskManager (di atas VFS apapun).
+type BlockManager interface {
+	Read(blockID BlockID, page *Page) error
+	Write(blockID BlockID, page *Page) error
+	WriteBlocks(writes []BlockWrite) error
+	ReadAsync(blockID BlockID, page *Page) *IORequest
+	SyncFile(fileName string) error
+	Append(fileName string) (BlockID, error)
+	Allocate(fileName string) (BlockID, error)
+	Free(blockID BlockID) error
+	RelocateBlock(fileName string, canMove func(from BlockID) (bool, error)) (from, to BlockID, ok bool, err error)
+	TruncateFree(fileName string) (int, error)
+	BlockLength(fileName string) (int, error)
+	BlockSize() int
+	FileBlockSize(fileName string) int
+	CreateFile(fileName string, blockSize int) error
+	DeleteFile(fileName string) error
+	Files() []string
+	GetDBDir() string
+}
+
 type DiskManager struct {
-	dbDir     string
-	blockSize int
-	isNew     bool
-	openFiles map[string]*os.File
+	fs              VFS // storage backend tempat file database disimpan
+	dbDir           string
+	blockSize       int               // block size default database
+	fileBlockSizes  map[string]int    // block size file baru yang beda dengan block size database (WithFileBlockSize)
+	fileTablespaces map[string]string // tablespace file baru yang bukan di tablespace default (WithFileTablespace)
+	isNew           bool
+	filesLatch      sync.Mutex // latch buat openFiles, fileLRU & pin count file
+	openFiles       map[string]*diskFile
+	fileLRU         *list.List       // file yang sedang dibuka & tidak dipakai (pins = 0). front = most recently used
+	maxOpenFiles    int              // jumlah maksimal file yang dibuka bersamaan
+	dwLatch         sync.Mutex       // serialize pemakaian file doublewrite
+	verifyChecksum  bool             // kalau false, Read tidak cek checksum block (buat benchmark)
+	doubleWrite     bool             // kalau true, WriteBlocks write block lewat doublewrite buffer dulu
+	directIO        bool             // kalau true, file block dibuka dengan O_DIRECT
+	mmapFiles       map[string]bool  // file yang diread lewat memory mapping
+	compression     map[string]Codec // file baru yang dicompress (WithCompression)
+
+	closingFiles map[string]chan struct{} // file yang sedang di fsync & close di luar filesLatch (dilindungi filesLatch), chan diclose setelah selesai
+
+	codecLatch sync.Mutex
+	fileCodecs map[string]Codec // cache codec per file (nil = tidak dicompress), dari header file .cmp
+
+	keyProvider KeyProvider // sumber master key (WithEncryption), nil kalau encryption tidak dipakai
+	keyLatch    sync.Mutex
+	fileKeySets map[string]*fileKeys // cache data key per file (nil = tidak dienkripsi), dari file .key
+
+	manifest     *manifest // superblock database (block size, versi format, daftar file)
+	upgradeHooks []UpgradeFunc
+
+	pageLogger atomic.Pointer[PageLogger] // dipanggil sebelum block diwrite (SetPageLogger), nil kalau tidak ada
+	lsnSource  atomic.Pointer[LSNSource]  // sumber block LSN (SetLSNSource), nil kalau block LSN tidak dicatat
+
+	readOnly bool         // database dibuka read-only (lock shared)
+	unlock   func() error // lepas lock database, nil kalau tidak ada lock
+
+	ioEngineKind  IOEngineKind
+	ioEngine      ioEngine // async I/O engine buat ReadAsync/WriteAsync, dibuat pas pertama dipakai
+	ioEngineLatch sync.Mutex
+}
+
+// DiskManagerOption. option buat konfigurasi DiskManager pas NewDiskManager.
+type DiskManagerOption func(*DiskManager)
+
+// WithChecksumVerification. enable/disable verifikasi checksum block pas Read. checksum tetap dihitung pas Write.
+func WithChecksumVerification(enabled bool) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.verifyChecksum = enabled
+	}
 }
 
-func NewDiskManager(dbDir string, blockSize int) *DiskManager {
-	_, err := os.Stat(dbDir)
-	if os.IsNotExist(err) {
-		os.Mkdir(dbDir, 0755)
+// NewDiskManager. open database di dbDir & lock database (lihat WithReadOnly). manifest dibuat kalau database baru, atau divalidasi (block size & versi format) kalau database sudah ada.
+// blockSize 0 artinya pakai block size yang tercatat di manifest (atau pkg.DEFAULT_PAGE_SIZE buat database baru).
+// block yang torn karena crash sebelumnya direpair dari doublewrite buffer.
+func NewDiskManager(dbDir string, blockSize int, opts ...DiskManagerOption) (*DiskManager, error) {
+	dm := &DiskManager{
+		fs:              NewOSFS(),
+		dbDir:           dbDir,
+		blockSize:       blockSize,
+		isNew:           false,
+		openFiles:       make(map[string]*diskFile),
+		closingFiles:    make(map[string]chan struct{}),
+		fileLRU:         list.New(),
+		maxOpenFiles:    defaultMaxOpenFiles,
+		mmapFiles:       make(map[string]bool),
+		compression:     make(map[string]Codec),
+		fileBlockSizes:  make(map[string]int),
+		fileTablespaces: make(map[string]string),
+		fileCodecs:      make(map[string]Codec),
+		fileKeySets:     make(map[string]*fileKeys),
+		verifyChecksum:  true,
+		doubleWrite:     true,
+	}
+	for _, opt := range opts {
+		opt(dm)
+	}
+	for _, fileBlockSize := range dm.fileBlockSizes {
+		err := validateBlockSize(fileBlockSize)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &DiskManager{
-		dbDir:     dbDir,
-		blockSize: blockSize,
-		isNew:     false,
-		openFiles: make(map[string]*os.File),
+	_, err := dm.fs.Stat(dbDir)
+	if os.IsNotExist(err) && !dm.readOnly {
+		err = dm.fs.MkdirAll(dbDir, 0755)
+	}
+	if err != nil {
+		return nil, err
 	}
+
+	err = dm.lockDatabase()
+	if err != nil {
+		return nil, err
+	}
+	err = dm.openManifest()
+	if err == nil {
+		err = dm.checkNewCompressedFiles()
+	}
+	if err == nil && !dm.readOnly {
+		err = dm.recoverDoubleWrite()
+	}
+	if err != nil {
+		return nil, errors.Join(err, dm.unlockDatabase())
+	}
+	return dm, nil
 }
 
-// Read. membaca satu block page dari disk.
+// Read. membaca satu block page dari disk. aman dipanggil dari banyak goroutine.
 func (dm *DiskManager) Read(blockID BlockID, page *Page) error {
-	filename := dm.dbDir + "/" + blockID.GetFilename()
-	f, err := dm.getFile(filename) // open file dengan nama filename
+	df, err := dm.acquireFile(blockID.GetFilename())
 	if err != nil {
 		return err
 	}
-	// Seek ke posisi blockID * blockSize
-	_, err = f.Seek(int64(blockID.GetBlockNum()*dm.blockSize), 0)
+	defer dm.releaseFile(df)
+	err = dm.checkPageSize(blockID, page)
 	if err != nil {
 		return err
 	}
-	_, err = f.Read(page.Contents()) // read byte array  dari file ke page (jumlah bytes yang diread sama dengan max_block_size dari page)
+	latch := df.blockLatch(blockID.GetBlockNum())
+	latch.RLock()
+	defer latch.RUnlock()
+
+	err = dm.readBlock(blockID, page)
 	if err != nil {
 		return err
 	}
+	if dm.verifyChecksum {
+		return dm.validateChecksum(blockID, page.Contents())
+	}
 	return nil
 }
 
-// Write. menulis satu block page ke disk.
+// readBlock. read isi block dari disk ke page tanpa verifikasi checksum.
+func (dm *DiskManager) readBlock(blockID BlockID, page *Page) error {
+	df, err := dm.acquireFile(blockID.GetFilename()) // open file dengan nama filename
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	// read byte array dari file di offset blockID * blockSize ke page (jumlah bytes yang diread sama dengan max_block_size dari page)
+	return dm.readBlockData(df, blockID, page.Contents())
+}
+
+// Write. menulis satu block page ke disk. block belum tentu persist sampai SyncFile/Sync dipanggil. aman dipanggil dari banyak goroutine.
 func (dm *DiskManager) Write(blockID BlockID, page *Page) error {
-	filename := dm.dbDir + "/" + blockID.GetFilename()
-	f, err := dm.getFile(filename)
+	df, err := dm.acquireFile(blockID.GetFilename())
 	if err != nil {
 		return err
 	}
+	defer dm.releaseFile(df)
+	err = dm.checkPageSize(blockID, page)
+	if err != nil {
+		return err
+	}
+	latch := df.blockLatch(blockID.GetBlockNum())
+	latch.Lock()
+	defer latch.Unlock()
 
-	_, err = f.Seek(int64(blockID.GetBlockNum()*dm.blockSize), 0) // write pada offset blockID * blockSize
+	w := BlockWrite{BlockID: blockID, Page: page}
+	err = dm.prepareWrite(&w)
 	if err != nil {
 		return err
 	}
+	return dm.writeBlockLocked(df, w)
+}
 
-	_, err = f.Write(page.Contents())
+// writeBlockLocked. write isi block w, checksum & block LSN nya. page image & block LSN w sudah dicatat prepareWrite. caller harus hold latch block.
+func (dm *DiskManager) writeBlockLocked(df *diskFile, w BlockWrite) error {
+	blockID, page := w.BlockID, w.Page
+	err := dm.writeBlockData(df, blockID, page.Contents()) // write pada offset blockID * blockSize
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = dm.writeChecksum(blockID, page.Contents()) // simpan checksum block di file checksum
+	if err != nil || w.lsn < 0 {
+		return err
+	}
+	return dm.writeBlockLSN(blockID, w.lsn)
 }
 
 // Append. menambahkan satu block page kosong (ukuran sama dengan max_block_size) ke disk.
 func (dm *DiskManager) Append(fileName string) (BlockID, error) {
-	newBlockNum, err := dm.BlockLength(fileName) // get  blockID baru pada file
+	df, err := dm.acquireFile(fileName)
 	if err != nil {
 		return BlockID{}, err
 	}
+	defer dm.releaseFile(df)
+	df.appendMu.Lock() // append dari goroutine lain harus dapat blockNum yang beda
+	defer df.appendMu.Unlock()
 
-	newBlock := NewBlockID(fileName, newBlockNum)
-	fileName = dm.dbDir + "/" + newBlock.GetFilename()
-
-	b := make([]byte, dm.blockSize) // buat block kosong dengan ukuran blockSize
-	f, err := dm.getFile(fileName)
+	newBlockNum, err := dm.BlockLength(fileName) // get  blockID baru pada file
 	if err != nil {
 		return BlockID{}, err
 	}
-	_, err = f.Seek(int64(newBlock.GetBlockNum()*dm.blockSize), 0)
+
+	newBlock := NewBlockID(fileName, newBlockNum)
+	latch := df.blockLatch(newBlockNum)
+	latch.Lock()
+	defer latch.Unlock()
+
+	b := alignedBuffer(dm.FileBlockSize(fileName)) // buat block kosong dengan ukuran block size file
+	err = dm.writeBlockData(df, newBlock, b)       // append block kosong ke file
 	if err != nil {
 		return BlockID{}, err
 	}
-	_, err = f.Write(b) // append block kosong ke file
+
+	err = dm.writeChecksum(newBlock, b)
 	if err != nil {
 		return BlockID{}, err
 	}
@@ -92,38 +247,84 @@ func (dm *DiskManager) Append(fileName string) (BlockID, error) {
 	return newBlock, nil
 }
 
-// blockLength. return jumlah block page pada file.
-func (dm *DiskManager) BlockLength(fileName string) (int, error) {
-	f, err := dm.getFile(fileName)
-	if err != nil {
-		return 0, err
+// SyncFile. fsync file fileName beserta file checksum (& file .cmp / .enc / .lsn) nya, semua write sebelumnya ke file tsb persist di disk.
+func (dm *DiskManager) SyncFile(fileName string) error {
+	names := []string{fileName, fileName + checksumFileExt}
+	if dm.lsnSource.Load() != nil {
+		names = append(names, fileName+blockLSNFileExt)
 	}
-	fi, err := f.Stat()
+	codec, keys, err := dm.blockTransforms(fileName)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if codec != nil {
+		names = append(names, fileName+compressionFileExt)
+	}
+	if keys != nil {
+		names = append(names, fileName+encryptionFileExt)
+	}
+	for _, name := range names {
+		df, err := dm.acquireFile(name)
+		if err != nil {
+			return err
+		}
+		err = df.sync()
+		dm.releaseFile(df)
+		if err != nil {
+			return err
+		}
 	}
-	return int(fi.Size() / int64(dm.blockSize)), nil
+	return nil
 }
 
-// getFile. get opened file dengan nama filename. jika file belum ada, maka file akan dibuat.
-func (dm *DiskManager) getFile(filename string) (*os.File, error) {
-	file, exists := dm.openFiles[filename]
+/*
+Sync. fsync semua file yang sedang dibuka (file yang sudah ditutup sudah di fsync pas ditutup) & tunggu file yang sedang ditutup selesai di fsync.
+file di pin di bawah filesLatch lalu di fsync di luar filesLatch, jadi acquireFile tidak menunggu fsync.
+*/
+func (dm *DiskManager) Sync() error {
+	dm.filesLatch.Lock()
+	files := make([]*diskFile, 0, len(dm.openFiles))
+	for _, df := range dm.openFiles {
+		dm.removeFromLRU(df)
+		df.pins++
+		files = append(files, df)
+	}
+	closing := slices.Collect(maps.Values(dm.closingFiles))
+	dm.filesLatch.Unlock()
+
 	var err error
-	if !exists {
-		file, err = os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_SYNC, 0644)
-		if err != nil {
-			return nil, err
+	for _, df := range files {
+		if err == nil {
+			err = df.sync()
 		}
+		dm.releaseFile(df)
 	}
+	for _, done := range closing {
+		<-done
+	}
+	return err
+}
 
-	dm.openFiles[filename] = file
-	return file, nil
+// BlockLength. return jumlah block page pada file.
+func (dm *DiskManager) BlockLength(fileName string) (int, error) {
+	df, err := dm.acquireFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.releaseFile(df)
+	size, err := df.f.Size()
+	if err != nil {
+		return 0, err
+	}
+	return int(size / int64(dm.FileBlockSize(fileName))), nil
 }
 
+// BlockSize. return block size database (block size file yang tidak punya block size sendiri, lihat FileBlockSize).
 func (dm *DiskManager) BlockSize() int {
 	return dm.blockSize
 }
 
+// IsNew. return true kalau database baru dibuat pas NewDiskManager (dbDir belum ada / kosong).
 func (dm *DiskManager) IsNew() bool {
 	return dm.isNew
 }
@@ -131,3 +332,8 @@ func (dm *DiskManager) IsNew() bool {
 func (dm *DiskManager) GetDBDir() string {
 	return dm.dbDir
 }
+
+// GetVFS. return storage backend yang dipakai DiskManager.
+func (dm *DiskManager) GetVFS() VFS {
+	return dm.fs
+}
@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,14 +11,18 @@ import (
 
 func TestReadWriteFile(t *testing.T) {
 
-	f1 := NewDiskManager("lintangdb", 4096)
+	f1, err := NewDiskManager("lintangdb", 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
 	page := NewPage(4096)
 	page.PutInt(0, 1)
 	page.PutInt(4, 2)
 	page.PutInt(8, 3)
 	page.PutString(12, "lintang")
 	newBlockID := NewBlockID("test.db", 0)
-	err := f1.Write(newBlockID, page)
+	err = f1.Write(newBlockID, page)
 	if err != nil {
 		t.Error(err)
 	}
@@ -30,3 +37,92 @@ func TestReadWriteFile(t *testing.T) {
 	assert.Equal(t, 3, pageReader.GetInt(8))
 	assert.Equal(t, "lintang", pageReader.GetString(12))
 }
+
+func TestReadChecksumMismatch(t *testing.T) {
+	f1, err := NewDiskManager("lintangdb", 4096)
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	blockID := NewBlockID("checksum.db", 1)
+	err = f1.Write(blockID, page)
+	assert.NoError(t, err)
+
+	// corrupt satu byte di block 1 langsung di file
+	f, err := os.OpenFile(filepath.Join("lintangdb", "checksum.db"), os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, int64(4096+5))
+	assert.NoError(t, err)
+	f.Close()
+
+	pageReader := NewPage(4096)
+	err = f1.Read(blockID, pageReader)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Contains(t, err.Error(), "checksum.db block 1")
+
+	// block 0 (kosong, belum pernah diwrite) tetap bisa diread
+	err = f1.Read(NewBlockID("checksum.db", 0), pageReader)
+	assert.NoError(t, err)
+
+	assert.NoError(t, f1.Close())
+	f2, err := NewDiskManager("lintangdb", 4096, WithChecksumVerification(false))
+	assert.NoError(t, err)
+	defer f2.Close()
+	err = f2.Read(blockID, pageReader)
+	assert.NoError(t, err)
+}
+
+func TestConcurrentDiskManager(t *testing.T) {
+	os.RemoveAll("lintangdb_concurrent")
+	defer os.RemoveAll("lintangdb_concurrent")
+
+	dm, err := NewDiskManager("lintangdb_concurrent", 4096)
+	assert.NoError(t, err)
+
+	const numWorkers = 8
+	const numBlocks = 50
+
+	// append concurrent: setiap goroutine harus dapat blockNum yang beda
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numBlocks; i++ {
+				blockID, err := dm.Append("test.db")
+				assert.NoError(t, err)
+				mu.Lock()
+				assert.False(t, seen[blockID.GetBlockNum()])
+				seen[blockID.GetBlockNum()] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	n, err := dm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, numWorkers*numBlocks, n)
+
+	// write & read concurrent ke block yang sama: read tidak boleh lihat block & checksum yang tidak match
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < numBlocks; i++ {
+				blockID := NewBlockID("test.db", i%4)
+				if w%2 == 0 {
+					page := NewPage(4096)
+					page.PutInt(0, w*numBlocks+i)
+					page.PutInt(4092, w*numBlocks+i)
+					assert.NoError(t, dm.Write(blockID, page))
+				} else {
+					page := NewPage(4096)
+					assert.NoError(t, dm.Read(blockID, page))
+					assert.Equal(t, page.GetInt(0), page.GetInt(4092))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,358 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+doublewrite buffer. buat proteksi torn page (block yang baru setengah ditulis pas crash).
+block yang mau diwrite ke disk ditulis dulu secara sequential ke file doublewrite & di fsync, baru setelah itu ditulis ke lokasi aslinya.
+kalau crash pas write ke lokasi asli, pas startup block di lokasi asli di repair pakai copy di file doublewrite.
+
+format satu slot di file doublewrite (ukuran slot = header + block size file block tsb):
+[magic (4)] [checksum (4)] [blockNum (4)] [filename length (4)] [block size (4)] [filename] ... padding ... [encryption entry (36)] | [isi block (block size)]
+isi block file yang dienkripsi disimpan dalam bentuk ciphertext, nonce & tag nya di encryption entry (semua 0 kalau file tidak dienkripsi).
+slot format lama ("DWB1") belum punya field block size, filename langsung di offset 16 & isi block berukuran block size database.
+*/
+const (
+	doubleWriteFile       = "doublewrite.buf"
+	doubleWriteMagicV1    = 0x44574231 // "DWB1"
+	doubleWriteMagic      = 0x44574232 // "DWB2"
+	doubleWriteHeaderSize = 512
+	doubleWriteEntryOff   = doubleWriteHeaderSize - encryptionEntrySize
+	maxDoubleWriteName    = doubleWriteEntryOff - 20
+)
+
+// BlockWrite. satu block yang mau diwrite lewat WriteBlocks.
+type BlockWrite struct {
+	BlockID BlockID
+	Page    *Page
+
+	lsn int // block LSN dari prepareWrite
+}
+
+// WithDoubleWrite. enable/disable doublewrite buffer pas WriteBlocks.
+func WithDoubleWrite(enabled bool) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.doubleWrite = enabled
+	}
+}
+
+// WriteBlocks. write beberapa block sekaligus. kalau doublewrite enabled, semua block ditulis dulu ke file doublewrite & di fsync,
+// lalu ditulis ke lokasi aslinya & di fsync. setelah itu file doublewrite dikosongkan.
+func (dm *DiskManager) WriteBlocks(writes []BlockWrite) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	if !dm.doubleWrite {
+		for _, w := range writes {
+			err := dm.Write(w.BlockID, w.Page)
+			if err != nil {
+				return err
+			}
+		}
+		return dm.syncWrittenFiles(writes)
+	}
+
+	// latch semua block dihold dari page image dicatat sampai block ditulis ke lokasi aslinya (lihat page_logger.go).
+	// page image & block LSN dicatat sebelum lock file doublewrite: log manager WriteBlocks block log sambil hold latch log nya
+	writes = slices.Clone(writes)
+	locked, err := dm.lockBlocks(writes)
+	if err != nil {
+		return err
+	}
+	defer locked.unlock()
+	for i := range writes {
+		err = dm.prepareWrite(&writes[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	dm.dwLatch.Lock() // satu batch doublewrite dalam satu waktu
+	defer dm.dwLatch.Unlock()
+	dw, err := dm.acquireFile(doubleWriteFile)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(dw)
+
+	bufSize := 0
+	for _, w := range writes {
+		bufSize += doubleWriteHeaderSize + len(w.Page.Contents())
+	}
+	buf := make([]byte, bufSize)
+	off := 0
+	for _, w := range writes {
+		slotSize := doubleWriteHeaderSize + len(w.Page.Contents())
+		err = dm.encodeDoubleWriteSlot(buf[off:off+slotSize], w)
+		if err != nil {
+			return err
+		}
+		off += slotSize
+	}
+
+	// write semua block ke file doublewrite secara sequential & fsync
+	_, err = dw.writeAt(buf, 0)
+	if err != nil {
+		return err
+	}
+	err = dw.sync()
+	if err != nil {
+		return err
+	}
+
+	// write block ke lokasi aslinya
+	for i, w := range writes {
+		err = dm.writeBlockLocked(locked.files[i], w)
+		if err != nil {
+			return err
+		}
+	}
+	err = dm.syncWrittenFiles(writes)
+	if err != nil {
+		return err
+	}
+
+	// semua block sudah aman di lokasi aslinya, kosongkan file doublewrite
+	err = dw.f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	return dw.sync()
+}
+
+// prepareWrite. log page image block w & ambil block LSN nya (dibaca setelah page image di log) tanpa write block. caller harus hold latch block.
+func (dm *DiskManager) prepareWrite(w *BlockWrite) error {
+	err := dm.logPage(w.BlockID, w.Page.Contents())
+	if err != nil {
+		return err
+	}
+	w.lsn = dm.blockLSN(w.BlockID)
+	return nil
+}
+
+// lockedBlocks. file & latch block yang dihold WriteBlocks sampai semua block selesai diwrite.
+type lockedBlocks struct {
+	dm      *DiskManager
+	files   []*diskFile     // file tiap block, urut sesuai writes
+	latches []*sync.RWMutex // latch yang dihold, urut (filename, stripe latch)
+}
+
+// lockBlocks. pin file & lock latch semua block di writes. latch dilock urut (filename, stripe latch) & latch yang sama cuma dilock sekali,
+// jadi beberapa WriteBlocks yang bersamaan tidak deadlock.
+func (dm *DiskManager) lockBlocks(writes []BlockWrite) (*lockedBlocks, error) {
+	lb := &lockedBlocks{dm: dm, files: make([]*diskFile, 0, len(writes))}
+	for _, w := range writes {
+		df, err := dm.acquireFile(w.BlockID.GetFilename())
+		if err != nil {
+			lb.unlock()
+			return nil, err
+		}
+		lb.files = append(lb.files, df)
+		err = dm.checkPageSize(w.BlockID, w.Page)
+		if err != nil {
+			lb.unlock()
+			return nil, err
+		}
+	}
+
+	order := make([]int, len(writes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Or(strings.Compare(writes[a].BlockID.GetFilename(), writes[b].BlockID.GetFilename()),
+			cmp.Compare(writes[a].BlockID.GetBlockNum()%blockLatchStripes, writes[b].BlockID.GetBlockNum()%blockLatchStripes))
+	})
+	for _, i := range order {
+		latch := lb.files[i].blockLatch(writes[i].BlockID.GetBlockNum())
+		if len(lb.latches) > 0 && lb.latches[len(lb.latches)-1] == latch {
+			continue
+		}
+		latch.Lock()
+		lb.latches = append(lb.latches, latch)
+	}
+	return lb, nil
+}
+
+// unlock. unlock semua latch & unpin file lockBlocks.
+func (lb *lockedBlocks) unlock() {
+	for i := len(lb.latches) - 1; i >= 0; i-- {
+		lb.latches[i].Unlock()
+	}
+	for _, df := range lb.files {
+		lb.dm.releaseFile(df)
+	}
+}
+
+// syncWrittenFiles. fsync semua file yang diwrite di writes (satu kali per file).
+func (dm *DiskManager) syncWrittenFiles(writes []BlockWrite) error {
+	synced := make(map[string]bool)
+	for _, w := range writes {
+		filename := w.BlockID.GetFilename()
+		if synced[filename] {
+			continue
+		}
+		err := dm.SyncFile(filename)
+		if err != nil {
+			return err
+		}
+		synced[filename] = true
+	}
+	return nil
+}
+
+// encodeDoubleWriteSlot. encode header & isi block ke slot doublewrite (berukuran doubleWriteHeaderSize + ukuran page).
+func (dm *DiskManager) encodeDoubleWriteSlot(slot []byte, w BlockWrite) error {
+	filename := w.BlockID.GetFilename()
+	if len(filename) > maxDoubleWriteName {
+		return fmt.Errorf("filename %s too long for doublewrite buffer", filename)
+	}
+	err := dm.checkPageSize(w.BlockID, w.Page)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(slot[0:], doubleWriteMagic)
+	binary.LittleEndian.PutUint32(slot[8:], uint32(w.BlockID.GetBlockNum()))
+	binary.LittleEndian.PutUint32(slot[12:], uint32(len(filename)))
+	binary.LittleEndian.PutUint32(slot[16:], uint32(len(w.Page.Contents())))
+	copy(slot[20:], filename)
+	keys, err := dm.fileKeySet(filename)
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		ciphertext, entry, err := keys.encryptBlock(w.BlockID, w.Page.Contents())
+		if err != nil {
+			return err
+		}
+		copy(slot[doubleWriteEntryOff:], entry)
+		copy(slot[doubleWriteHeaderSize:], ciphertext)
+	} else {
+		copy(slot[doubleWriteHeaderSize:], w.Page.Contents())
+	}
+	binary.LittleEndian.PutUint32(slot[4:], pageChecksum(slot)) // checksum dihitung dengan field checksum = 0
+	return nil
+}
+
+// doubleWriteSlotSize. return ukuran slot doublewrite dari header slot. slot format lama berukuran header + defaultBlockSize.
+// return false kalau header bukan header slot doublewrite.
+func doubleWriteSlotSize(header []byte, defaultBlockSize int) (int, bool) {
+	switch binary.LittleEndian.Uint32(header[0:]) {
+	case doubleWriteMagicV1:
+		return doubleWriteHeaderSize + defaultBlockSize, true
+	case doubleWriteMagic:
+		blockSize := int(binary.LittleEndian.Uint32(header[16:]))
+		if validateBlockSize(blockSize) != nil {
+			return 0, false
+		}
+		return doubleWriteHeaderSize + blockSize, true
+	}
+	return 0, false
+}
+
+// decodeDoubleWriteSlot. decode slot doublewrite. return false kalau slot kosong/torn (checksum tidak cocok).
+// isi block dienkripsi kalau encryption entry (return ke-3) tidak nil.
+func decodeDoubleWriteSlot(slot []byte) (BlockID, []byte, []byte, bool) {
+	nameOff := 20
+	switch binary.LittleEndian.Uint32(slot[0:]) {
+	case doubleWriteMagicV1:
+		nameOff = 16
+	case doubleWriteMagic:
+	default:
+		return BlockID{}, nil, nil, false
+	}
+	checksum := binary.LittleEndian.Uint32(slot[4:])
+	binary.LittleEndian.PutUint32(slot[4:], 0)
+	if pageChecksum(slot) != checksum {
+		return BlockID{}, nil, nil, false
+	}
+	nameLen := int(binary.LittleEndian.Uint32(slot[12:]))
+	if nameOff+nameLen > doubleWriteEntryOff {
+		return BlockID{}, nil, nil, false
+	}
+	blockID := NewBlockID(string(slot[nameOff:nameOff+nameLen]), int(binary.LittleEndian.Uint32(slot[8:])))
+	entry := slot[doubleWriteEntryOff:doubleWriteHeaderSize]
+	if binary.LittleEndian.Uint32(entry) == 0 {
+		entry = nil // data key version mulai dari 1
+	}
+	return blockID, slot[doubleWriteHeaderSize:], entry, true
+}
+
+// recoverDoubleWrite. dipanggil pas startup. repair block yang torn di lokasi aslinya pakai copy dari file doublewrite.
+func (dm *DiskManager) recoverDoubleWrite() error {
+	dw, err := dm.acquireFile(doubleWriteFile)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(dw)
+	size, err := dw.f.Size()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, doubleWriteHeaderSize)
+	for off := int64(0); off+doubleWriteHeaderSize <= size; {
+		_, err = dw.f.ReadAt(header, off)
+		if err != nil {
+			return err
+		}
+		slotSize, ok := doubleWriteSlotSize(header, dm.blockSize)
+		if !ok || off+int64(slotSize) > size {
+			// slot torn: crash terjadi pas write ke file doublewrite, block di lokasi asli belum disentuh
+			break
+		}
+		slot := make([]byte, slotSize)
+		_, err = dw.f.ReadAt(slot, off)
+		if err != nil {
+			return err
+		}
+		off += int64(slotSize)
+		blockID, contents, entry, ok := decodeDoubleWriteSlot(slot)
+		if !ok {
+			// slot torn: crash terjadi pas write ke file doublewrite, block di lokasi asli belum disentuh
+			break
+		}
+		if entry != nil {
+			keys, err := dm.fileKeySet(blockID.GetFilename())
+			if err != nil {
+				return err
+			}
+			if keys == nil {
+				return fmt.Errorf("%w: doublewrite copy of block %d of unencrypted file %s", ErrDecryptionFailed, blockID.GetBlockNum(), blockID.GetFilename())
+			}
+			err = keys.decryptBlock(blockID, contents, entry)
+			if err != nil {
+				return err
+			}
+		}
+
+		home := NewPage(len(contents))
+		err = dm.readBlock(blockID, home)
+		if err == nil && bytes.Equal(home.Contents(), contents) && dm.validateChecksum(blockID, contents) == nil {
+			continue
+		}
+		// block di lokasi asli (atau checksumnya) torn / belum ditulis, restore dari copy doublewrite
+		err = dm.Write(blockID, NewPageFromByteSlice(contents))
+		if err != nil {
+			return fmt.Errorf("failed to repair block %d of file %s from doublewrite buffer: %w", blockID.GetBlockNum(), blockID.GetFilename(), err)
+		}
+		err = dm.SyncFile(blockID.GetFilename())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dw.f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	return dw.sync()
+}
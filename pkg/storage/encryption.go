@@ -0,0 +1,550 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+encryption at rest. tiap block file dienkripsi dengan AES-256-GCM pakai data key per file. ciphertext ditulis di slot block (ukuran sama dengan plaintext),
+nonce & tag GCM tiap block disimpan di file <filename>.enc: [key version (4)] [nonce (12)] [tag (16)] per block. blockID jadi additional data, jadi block yang
+dipindah/ditukar di file tidak bisa didecrypt.
+data key disimpan di file <filename>.key, diwrap (AES-GCM) dengan master key dari KeyProvider. satu file bisa punya beberapa versi data key selama RotateDataKey.
+*/
+const (
+	encryptionFileExt   = ".enc"
+	keyFileExt          = ".key"
+	keyFileMagic        = 0x3159454b // "KEY1"
+	dataKeySize         = 32
+	gcmNonceSize        = 12
+	gcmTagSize          = 16
+	encryptionEntrySize = 4 + gcmNonceSize + gcmTagSize
+)
+
+var (
+	ErrDecryptionFailed = errors.New("block decryption failed")
+	ErrNoKeyProvider    = errors.New("file is encrypted but no key provider is configured")
+)
+
+// KeyProvider. sumber master key buat wrap/unwrap data key file. CurrentKey dipakai buat wrap data key baru, Key buat unwrap data key lama.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+// WithEncryption. enkripsi semua file block yang baru dibuat dengan data key yang diwrap master key dari provider.
+// file lama yang tidak dienkripsi tetap tidak dienkripsi.
+func WithEncryption(provider KeyProvider) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.keyProvider = provider
+	}
+}
+
+// FileKeyProvider. KeyProvider dari file lokal, satu master key per baris: "<id> <key hex>". baris terakhir adalah current key.
+type FileKeyProvider struct {
+	mu   sync.RWMutex
+	path string
+	ids  []string
+	keys map[string][]byte
+}
+
+// NewFileKeyProvider. load master key dari file path. kalau file belum ada, dibuat dengan satu master key random.
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{path: path, keys: make(map[string][]byte)}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, err = p.Rotate()
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid key file %s: line %q", path, line)
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: key %s: %w", path, fields[0], err)
+		}
+		p.ids = append(p.ids, fields[0])
+		p.keys[fields[0]] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.ids) == 0 {
+		return nil, fmt.Errorf("invalid key file %s: no keys", path)
+	}
+	return p, nil
+}
+
+func (p *FileKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	id := p.ids[len(p.ids)-1]
+	return id, p.keys[id], nil
+}
+
+func (p *FileKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("master key %s not found in %s", id, p.path)
+	}
+	return key, nil
+}
+
+// Rotate. generate master key baru & append ke file key. key baru jadi current key, key lama tetap ada buat unwrap data key lama (lihat RotateMasterKey).
+func (p *FileKeyProvider) Rotate() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("k%d", len(p.ids)+1)
+
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprintf(f, "%s %s\n", id, hex.EncodeToString(key))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	p.ids = append(p.ids, id)
+	p.keys[id] = key
+	return id, nil
+}
+
+// dataKey. satu versi data key file.
+type dataKey struct {
+	version  uint32
+	masterID string
+	key      []byte
+	aead     cipher.AEAD
+}
+
+// fileKeys. semua versi data key satu file. block baru dienkripsi dengan current.
+type fileKeys struct {
+	mu      sync.RWMutex
+	keys    map[uint32]*dataKey
+	current uint32
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyFileAAD. additional data buat wrap data key: data key tidak bisa dipindah ke file / versi lain.
+func keyFileAAD(filename string, version uint32) []byte {
+	return binary.LittleEndian.AppendUint32([]byte(filename), version)
+}
+
+// blockAAD. additional data buat enkripsi block.
+func blockAAD(blockID BlockID) []byte {
+	return binary.LittleEndian.AppendUint64([]byte(blockID.GetFilename()), uint64(blockID.GetBlockNum()))
+}
+
+// fileKeySet. return data key file filename, nil kalau file tidak dienkripsi. file .key dibuat kalau file baru & encryption enabled.
+func (dm *DiskManager) fileKeySet(filename string) (*fileKeys, error) {
+	dm.keyLatch.Lock()
+	defer dm.keyLatch.Unlock()
+	keys, ok := dm.fileKeySets[filename]
+	if ok {
+		return keys, nil
+	}
+	if !isBlockFile(filename) {
+		dm.fileKeySets[filename] = nil
+		return nil, nil
+	}
+
+	_, err := dm.fs.Stat(dm.filePath(filename + keyFileExt))
+	switch {
+	case err == nil:
+		keys, err = dm.readKeyFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	case errors.Is(err, fs.ErrNotExist) && dm.keyProvider != nil:
+		info, err := dm.fs.Stat(dm.filePath(filename))
+		if err == nil && info.Size() > 0 {
+			break // file lama tanpa encryption
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		keys = &fileKeys{keys: make(map[uint32]*dataKey)}
+		err = dm.addDataKey(filename, keys)
+		if err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	dm.fileKeySets[filename] = keys
+	return keys, nil
+}
+
+// addDataKey. generate data key versi baru, jadikan current & simpan ke file .key.
+func (dm *DiskManager) addDataKey(filename string, keys *fileKeys) error {
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	version := keys.current + 1
+	masterID, _, err := dm.currentMasterKey()
+	if err != nil {
+		return err
+	}
+	newKeys := make(map[uint32]*dataKey, len(keys.keys)+1)
+	for v, k := range keys.keys {
+		newKeys[v] = k
+	}
+	newKeys[version] = &dataKey{version: version, masterID: masterID, key: key, aead: aead}
+	err = dm.writeKeyFile(filename, newKeys)
+	if err != nil {
+		return err
+	}
+	keys.keys = newKeys
+	keys.current = version
+	return nil
+}
+
+func (dm *DiskManager) currentMasterKey() (string, cipher.AEAD, error) {
+	if dm.keyProvider == nil {
+		return "", nil, ErrNoKeyProvider
+	}
+	id, key, err := dm.keyProvider.CurrentKey()
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := newGCM(key)
+	return id, aead, err
+}
+
+/*
+writeKeyFile. tulis semua data key file filename (diwrap master key) ke file .key lewat file sementara + rename, jadi file .key tidak pernah torn.
+format: [magic (4)] [jumlah key (4)] lalu per key: [version (4)] [master key id length (2)] [master key id] [wrapped key length (2)] [nonce + wrapped key + tag].
+*/
+func (dm *DiskManager) writeKeyFile(filename string, keys map[uint32]*dataKey) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot write data keys of %s", ErrReadOnly, filename)
+	}
+	buf := binary.LittleEndian.AppendUint32(nil, keyFileMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(keys)))
+	for _, k := range keys {
+		masterKey, err := dm.keyProvider.Key(k.masterID)
+		if err != nil {
+			return err
+		}
+		master, err := newGCM(masterKey)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcmNonceSize)
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return err
+		}
+		wrapped := master.Seal(nonce, nonce, k.key, keyFileAAD(filename, k.version))
+
+		buf = binary.LittleEndian.AppendUint32(buf, k.version)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(k.masterID)))
+		buf = append(buf, k.masterID...)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(wrapped)))
+		buf = append(buf, wrapped...)
+	}
+
+	tmpPath := dm.filePath(filename + keyFileExt + ".tmp")
+	f, err := dm.fs.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(buf, 0)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return dm.fs.Rename(tmpPath, dm.filePath(filename+keyFileExt))
+}
+
+// readKeyFile. read & unwrap semua data key file filename dari file .key.
+func (dm *DiskManager) readKeyFile(filename string) (*fileKeys, error) {
+	if dm.keyProvider == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoKeyProvider, filename)
+	}
+	f, err := dm.fs.OpenFile(dm.filePath(filename+keyFileExt), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	_, err = f.ReadAt(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := fmt.Errorf("invalid key file for %s", filename)
+	if len(buf) < 8 || binary.LittleEndian.Uint32(buf) != keyFileMagic {
+		return nil, invalid
+	}
+	count := int(binary.LittleEndian.Uint32(buf[4:]))
+	buf = buf[8:]
+	keys := &fileKeys{keys: make(map[uint32]*dataKey, count)}
+	for i := 0; i < count; i++ {
+		if len(buf) < 6 {
+			return nil, invalid
+		}
+		version := binary.LittleEndian.Uint32(buf)
+		idLen := int(binary.LittleEndian.Uint16(buf[4:]))
+		buf = buf[6:]
+		if len(buf) < idLen+2 {
+			return nil, invalid
+		}
+		masterID := string(buf[:idLen])
+		wrappedLen := int(binary.LittleEndian.Uint16(buf[idLen:]))
+		buf = buf[idLen+2:]
+		if len(buf) < wrappedLen || wrappedLen < gcmNonceSize {
+			return nil, invalid
+		}
+		wrapped := buf[:wrappedLen]
+		buf = buf[wrappedLen:]
+
+		masterKey, err := dm.keyProvider.Key(masterID)
+		if err != nil {
+			return nil, err
+		}
+		master, err := newGCM(masterKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := master.Open(nil, wrapped[:gcmNonceSize], wrapped[gcmNonceSize:], keyFileAAD(filename, version))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key %d of %s with master key %s: %w", version, filename, masterID, err)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		keys.keys[version] = &dataKey{version: version, masterID: masterID, key: key, aead: aead}
+		keys.current = max(keys.current, version)
+	}
+	return keys, nil
+}
+
+// encryptBlock. enkripsi data block, return ciphertext (ukuran sama dengan data) & entry [version][nonce][tag] buat file .enc.
+func (keys *fileKeys) encryptBlock(blockID BlockID, data []byte) ([]byte, []byte, error) {
+	keys.mu.RLock()
+	k := keys.keys[keys.current]
+	keys.mu.RUnlock()
+
+	entry := make([]byte, encryptionEntrySize)
+	binary.LittleEndian.PutUint32(entry, k.version)
+	nonce := entry[4 : 4+gcmNonceSize]
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed := k.aead.Seal(make([]byte, 0, len(data)+gcmTagSize), nonce, data, blockAAD(blockID))
+	copy(entry[4+gcmNonceSize:], sealed[len(data):])
+	return sealed[:len(data)], entry, nil
+}
+
+// decryptBlock. decrypt ciphertext block (in place) pakai entry dari file .enc.
+func (keys *fileKeys) decryptBlock(blockID BlockID, data, entry []byte) error {
+	keys.mu.RLock()
+	k, ok := keys.keys[binary.LittleEndian.Uint32(entry)]
+	keys.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: file %s block %d: unknown data key version %d", ErrDecryptionFailed, blockID.GetFilename(), blockID.GetBlockNum(), binary.LittleEndian.Uint32(entry))
+	}
+	sealed := append(data[:len(data):len(data)], entry[4+gcmNonceSize:]...)
+	_, err := k.aead.Open(data[:0], entry[4:4+gcmNonceSize], sealed, blockAAD(blockID))
+	if err != nil {
+		return fmt.Errorf("%w: file %s block %d", ErrDecryptionFailed, blockID.GetFilename(), blockID.GetBlockNum())
+	}
+	return nil
+}
+
+func (dm *DiskManager) readEncryptionEntry(blockID BlockID) ([]byte, error) {
+	df, err := dm.acquireFile(blockID.GetFilename() + encryptionFileExt)
+	if err != nil {
+		return nil, err
+	}
+	defer dm.releaseFile(df)
+	entry := make([]byte, encryptionEntrySize)
+	n, err := df.f.ReadAt(entry, int64(blockID.GetBlockNum()*encryptionEntrySize))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n < encryptionEntrySize {
+		return nil, fmt.Errorf("%w: file %s block %d: missing nonce", ErrDecryptionFailed, blockID.GetFilename(), blockID.GetBlockNum())
+	}
+	return entry, nil
+}
+
+func (dm *DiskManager) writeEncryptionEntry(blockID BlockID, entry []byte) error {
+	df, err := dm.acquireFile(blockID.GetFilename() + encryptionFileExt)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	_, err = df.writeAt(entry, int64(blockID.GetBlockNum()*encryptionEntrySize))
+	return err
+}
+
+/*
+RotateMasterKey. wrap ulang data key semua file terenkripsi di dbDir dengan current master key dari KeyProvider (setelah master key baru dibuat, mis. FileKeyProvider.Rotate).
+data block tidak diencrypt ulang. setelah selesai, master key lama tidak dibutuhkan lagi.
+*/
+func (dm *DiskManager) RotateMasterKey() error {
+	entries, err := dm.fs.ReadDir(dm.dbDir)
+	if err != nil {
+		return err
+	}
+	masterID, _, err := dm.currentMasterKey()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filename, ok := strings.CutSuffix(entry.Name(), keyFileExt)
+		if !ok {
+			continue
+		}
+		keys, err := dm.fileKeySet(filename)
+		if err != nil {
+			return err
+		}
+		keys.mu.Lock()
+		rewrapped := make(map[uint32]*dataKey, len(keys.keys))
+		for v, k := range keys.keys {
+			nk := *k
+			nk.masterID = masterID
+			rewrapped[v] = &nk
+		}
+		err = dm.writeKeyFile(filename, rewrapped)
+		if err == nil {
+			keys.keys = rewrapped
+		}
+		keys.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to rewrap data keys of %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+/*
+RotateDataKey. generate data key baru buat file filename & encrypt ulang semua block nya dengan key baru (online: tiap block di re-encrypt di bawah latch block nya).
+block yang diwrite selama rotasi langsung pakai key baru. setelah semua block di re-encrypt, data key lama dihapus dari file .key.
+*/
+func (dm *DiskManager) RotateDataKey(filename string) error {
+	keys, err := dm.fileKeySet(filename)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("file %s is not encrypted", filename)
+	}
+	err = dm.addDataKey(filename, keys)
+	if err != nil {
+		return err
+	}
+
+	numBlocks, err := dm.BlockLength(filename)
+	if err != nil {
+		return err
+	}
+	page := NewPage(dm.FileBlockSize(filename))
+	for blockNum := 0; blockNum < numBlocks; blockNum++ {
+		err = dm.reencryptBlock(NewBlockID(filename, blockNum), page)
+		if err != nil {
+			return err
+		}
+	}
+	err = dm.SyncFile(filename)
+	if err != nil {
+		return err
+	}
+
+	dm.dwLatch.Lock() // tunggu batch doublewrite yang mungkin masih pakai data key lama selesai
+	defer dm.dwLatch.Unlock()
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	current := map[uint32]*dataKey{keys.current: keys.keys[keys.current]}
+	err = dm.writeKeyFile(filename, current)
+	if err != nil {
+		return err
+	}
+	keys.keys = current
+	return nil
+}
+
+// reencryptBlock. read & write ulang satu block di bawah latch block nya, jadi block dienkripsi dengan current data key.
+func (dm *DiskManager) reencryptBlock(blockID BlockID, page *Page) error {
+	df, err := dm.acquireFile(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	latch := df.blockLatch(blockID.GetBlockNum())
+	latch.Lock()
+	defer latch.Unlock()
+	err = dm.readBlock(blockID, page)
+	if err != nil {
+		return err
+	}
+	return dm.writeBlockData(df, blockID, page.Contents())
+}
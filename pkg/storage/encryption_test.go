@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readRawFile. read isi file apa adanya dari VFS (tanpa decrypt).
+func readRawFile(t *testing.T, fs VFS, path string) []byte {
+	f, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	assert.NoError(t, err)
+	defer f.Close()
+	size, err := f.Size()
+	assert.NoError(t, err)
+	buf := make([]byte, size)
+	_, err = f.ReadAt(buf, 0)
+	assert.NoError(t, err)
+	return buf
+}
+
+func TestEncryption(t *testing.T) {
+	fs := NewMemFS()
+	provider, err := NewFileKeyProvider(filepath.Join(t.TempDir(), "master.key"))
+	assert.NoError(t, err)
+
+	dm, err := NewDiskManager("lintangdb_enc", 16384, WithVFS(fs), WithEncryption(provider),
+		WithCompression(FlateCodec{}, "compressed.db"))
+	assert.NoError(t, err)
+
+	secret := strings.Repeat("lintang birda saputra ", 10)
+	for _, filename := range []string{"table.db", "compressed.db"} {
+		for i := 0; i < 4; i++ {
+			page := NewPage(16384)
+			page.PutString(0, secret)
+			assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: NewBlockID(filename, i), Page: page}}))
+		}
+		raw := readRawFile(t, fs, dm.filePath(filename))
+		assert.False(t, bytes.Contains(raw, []byte("lintang")))
+	}
+
+	checkPages := func(dm *DiskManager) {
+		for _, filename := range []string{"table.db", "compressed.db"} {
+			for i := 0; i < 4; i++ {
+				page := NewPage(16384)
+				assert.NoError(t, dm.Read(NewBlockID(filename, i), page))
+				assert.Equal(t, secret, page.GetString(0))
+			}
+		}
+	}
+	checkPages(dm)
+
+	// block yang ditukar di file tidak bisa didecrypt (blockID jadi additional data)
+	raw := readRawFile(t, fs, dm.filePath("table.db"))
+	f, err := fs.OpenFile(dm.filePath("table.db"), os.O_RDWR, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteAt(raw[:16384], 16384)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, dm.CloseAll())
+	assert.ErrorIs(t, dm.Read(NewBlockID("table.db", 1), NewPage(16384)), ErrDecryptionFailed)
+	page := NewPage(16384)
+	page.PutString(0, secret)
+	assert.NoError(t, dm.Write(NewBlockID("table.db", 1), page))
+
+	// file terenkripsi tidak bisa dibuka tanpa key provider
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_enc", 16384, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, dm.Read(NewBlockID("table.db", 0), NewPage(16384)), ErrNoKeyProvider)
+
+	// rotasi master key: data key diwrap ulang, master key lama tidak dibutuhkan lagi
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_enc", 16384, WithVFS(fs), WithEncryption(provider))
+	assert.NoError(t, err)
+	newID, err := provider.Rotate()
+	assert.NoError(t, err)
+	assert.NoError(t, dm.RotateMasterKey())
+	newKey, err := provider.Key(newID)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_enc", 16384, WithVFS(fs), WithEncryption(staticKeyProvider{newID: newKey}))
+	assert.NoError(t, err)
+	checkPages(dm)
+
+	// rotasi data key: semua block diencrypt ulang dengan data key baru
+	before := readRawFile(t, fs, dm.filePath("table.db"))
+	assert.NoError(t, dm.RotateDataKey("table.db"))
+	after := readRawFile(t, fs, dm.filePath("table.db"))
+	assert.NotEqual(t, before, after)
+	keys, err := dm.fileKeySet("table.db")
+	assert.NoError(t, err)
+	assert.Len(t, keys.keys, 1)
+	assert.Equal(t, uint32(2), keys.current)
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_enc", 16384, WithVFS(fs), WithEncryption(provider))
+	assert.NoError(t, err)
+	checkPages(dm)
+}
+
+func TestEncryptionDoubleWriteRecovery(t *testing.T) {
+	fs := NewMemFS()
+	provider, err := NewFileKeyProvider(filepath.Join(t.TempDir(), "master.key"))
+	assert.NoError(t, err)
+	dm, err := NewDiskManager("lintangdb_enc_dw", 4096, WithVFS(fs), WithEncryption(provider))
+	assert.NoError(t, err)
+
+	blockID := NewBlockID("table.db", 0)
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: blockID, Page: page}}))
+
+	// simulasi crash: block baru sudah masuk file doublewrite (terenkripsi), lokasi aslinya torn
+	page.PutString(0, "birda")
+	slot := make([]byte, doubleWriteHeaderSize+4096)
+	assert.NoError(t, dm.encodeDoubleWriteSlot(slot, BlockWrite{BlockID: blockID, Page: page}))
+	assert.False(t, bytes.Contains(slot, []byte("birda")))
+	dw, err := fs.OpenFile(dm.filePath(doubleWriteFile), os.O_RDWR, 0)
+	assert.NoError(t, err)
+	_, err = dw.WriteAt(slot, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, dw.Close())
+	f, err := fs.OpenFile(dm.filePath("table.db"), os.O_RDWR, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteAt(make([]byte, 2048), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, dm.Close())
+
+	dm, err = NewDiskManager("lintangdb_enc_dw", 4096, WithVFS(fs), WithEncryption(provider))
+	assert.NoError(t, err)
+	got := NewPage(4096)
+	assert.NoError(t, dm.Read(blockID, got))
+	assert.Equal(t, "birda", got.GetString(0))
+}
+
+// staticKeyProvider. KeyProvider dengan master key tetap (id -> key).
+type staticKeyProvider map[string][]byte
+
+func (p staticKeyProvider) CurrentKey() (string, []byte, error) {
+	for id, key := range p {
+		return id, key, nil
+	}
+	return "", nil, os.ErrNotExist
+}
+
+func (p staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return key, nil
+}
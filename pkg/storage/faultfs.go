@@ -0,0 +1,452 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ErrPowerLoss. dikembalikan semua operasi FaultFS setelah simulasi power loss, sampai Restart dipanggil.
+var ErrPowerLoss = errors.New("simulated power loss")
+
+const faultSectorSize = 512 // write yang torn hanya persist kelipatan sector
+
+/*
+FaultFS. VFS wrapper buat crash testing. bisa:
+  - drop write yang belum di fsync pas crash.
+  - tear write di sector boundary (cuma sebagian sector dari write yang persist).
+  - return EIO buat block tertentu.
+  - simulasi power loss setelah sejumlah operasi write/sync/truncate.
+
+file yang dibuka dengan O_SYNC dianggap langsung persist setiap write.
+*/
+type FaultFS struct {
+	mu           sync.Mutex
+	fs           VFS
+	blockSize    int
+	rnd          *rand.Rand
+	ops          int  // jumlah operasi write/sync/truncate sejak Restart
+	crashAfter   int  // power loss pas operasi ke crashAfter. 0 = tidak pernah
+	crashed      bool // true setelah power loss, sampai Restart
+	dropUnsynced bool
+	tornWrites   bool
+	failBlocks   map[string]map[int]bool // filename -> blockNum yang read/write nya return EIO
+	files        map[string]*faultFileState
+	locks        memLocks // lock database, dilepas pas crash (proses yang hold lock mati)
+}
+
+// faultFileState. state satu file di FaultFS, dishare semua handle file tsb.
+type faultFileState struct {
+	inner File
+	undo  []undoRecord // write yang belum di fsync, buat di-rollback pas crash
+}
+
+// undoRecord. isi file sebelum satu write/truncate yang belum di fsync.
+type undoRecord struct {
+	off     int64
+	old     []byte
+	oldSize int64
+	newLen  int // panjang data yang ditulis. 0 buat truncate
+}
+
+// NewFaultFS. wrap VFS fs. blockSize dipakai buat nentuin range block yang di FailBlock. seed buat random torn write.
+func NewFaultFS(fs VFS, blockSize int, seed int64) *FaultFS {
+	return &FaultFS{
+		fs:           fs,
+		blockSize:    blockSize,
+		rnd:          rand.New(rand.NewSource(seed)),
+		dropUnsynced: true,
+		failBlocks:   make(map[string]map[int]bool),
+		files:        make(map[string]*faultFileState),
+	}
+}
+
+// SetDropUnsyncedWrites. kalau true, write yang belum di fsync hilang pas crash.
+func (ffs *FaultFS) SetDropUnsyncedWrites(enabled bool) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.dropUnsynced = enabled
+}
+
+// SetTornWrites. kalau true, write yang kena crash (& write yang belum di fsync) cuma persist sebagian sector.
+func (ffs *FaultFS) SetTornWrites(enabled bool) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.tornWrites = enabled
+}
+
+// FailBlock. read/write ke block blockNum di file filename return EIO.
+func (ffs *FaultFS) FailBlock(filename string, blockNum int) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.failBlocks[filename] == nil {
+		ffs.failBlocks[filename] = make(map[int]bool)
+	}
+	ffs.failBlocks[filename][blockNum] = true
+}
+
+// ClearFailures. hapus semua block yang di FailBlock.
+func (ffs *FaultFS) ClearFailures() {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.failBlocks = make(map[string]map[int]bool)
+}
+
+// CrashAfter. simulasi power loss pas operasi write/sync/truncate ke-n dari sekarang.
+func (ffs *FaultFS) CrashAfter(n int) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.crashAfter = ffs.ops + n
+}
+
+// Crash. simulasi power loss sekarang.
+func (ffs *FaultFS) Crash() error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	return ffs.crash()
+}
+
+// Lock. lock file name in-process. semua lock dilepas pas crash.
+func (ffs *FaultFS) Lock(name string, exclusive bool) (func() error, error) {
+	return ffs.locks.lock(name, exclusive)
+}
+
+// Restart. nyalakan lagi FaultFS setelah power loss. file yang sebelumnya dibuka harus dibuka ulang.
+func (ffs *FaultFS) Restart() {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.crashed = false
+	ffs.crashAfter = 0
+	ffs.ops = 0
+}
+
+// Crashed. return true kalau FaultFS sedang dalam kondisi power loss.
+func (ffs *FaultFS) Crashed() bool {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	return ffs.crashed
+}
+
+// crash. rollback semua write yang belum di fsync (sebagian kalau tornWrites). caller harus hold ffs.mu.
+func (ffs *FaultFS) crash() error {
+	ffs.crashed = true
+	ffs.locks.reset()
+	for _, state := range ffs.files {
+		for i := len(state.undo) - 1; i >= 0; i-- {
+			rec := state.undo[i]
+			keep := 0 // jumlah bytes dari write yang tetap persist
+			if ffs.tornWrites && rec.newLen > 0 {
+				keep = ffs.rnd.Intn(rec.newLen/faultSectorSize+1) * faultSectorSize
+			}
+			if keep < len(rec.old) {
+				_, err := state.inner.WriteAt(rec.old[keep:], rec.off+int64(keep))
+				if err != nil {
+					return err
+				}
+			}
+			size, err := state.inner.Size()
+			if err != nil {
+				return err
+			}
+			newSize := max(rec.oldSize, rec.off+int64(keep))
+			if rec.newLen == 0 {
+				newSize = rec.oldSize
+			}
+			if size > newSize {
+				err = state.inner.Truncate(newSize)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state.undo = nil
+	}
+	return nil
+}
+
+// beginOp. cek power loss sebelum operasi write/sync/truncate. return true kalau operasi ini kena power loss. caller harus hold ffs.mu.
+func (ffs *FaultFS) beginOp() (bool, error) {
+	if ffs.crashed {
+		return false, ErrPowerLoss
+	}
+	ffs.ops++
+	return ffs.crashAfter > 0 && ffs.ops >= ffs.crashAfter, nil
+}
+
+// checkFailBlock. return EIO kalau range [off, off+n) kena block yang di FailBlock. caller harus hold ffs.mu.
+func (ffs *FaultFS) checkFailBlock(name string, op string, off int64, n int) error {
+	blocks := ffs.failBlocks[filepath.Base(name)]
+	if len(blocks) == 0 || n == 0 {
+		return nil
+	}
+	first := int(off / int64(ffs.blockSize))
+	last := int((off + int64(n) - 1) / int64(ffs.blockSize))
+	for b := first; b <= last; b++ {
+		if blocks[b] {
+			return &fs.PathError{Op: op, Path: name, Err: syscall.EIO}
+		}
+	}
+	return nil
+}
+
+func (ffs *FaultFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return nil, ErrPowerLoss
+	}
+
+	name = cleanPath(name)
+	state, ok := ffs.files[name]
+	if ok && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if !ok || flag&os.O_TRUNC != 0 {
+		inner, err := ffs.fs.OpenFile(name, flag&^os.O_SYNC, perm)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			state.inner.Close()
+			state.inner = inner
+		} else {
+			state = &faultFileState{inner: inner}
+			ffs.files[name] = state
+		}
+	}
+	return &faultFile{ffs: ffs, name: name, state: state, sync: flag&os.O_SYNC != 0}, nil
+}
+
+func (ffs *FaultFS) Remove(name string) error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return ErrPowerLoss
+	}
+	name = cleanPath(name)
+	if state, ok := ffs.files[name]; ok {
+		state.inner.Close()
+		delete(ffs.files, name)
+	}
+	return ffs.fs.Remove(name)
+}
+
+func (ffs *FaultFS) Rename(oldpath, newpath string) error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return ErrPowerLoss
+	}
+	oldpath, newpath = cleanPath(oldpath), cleanPath(newpath)
+	err := ffs.fs.Rename(oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	if state, ok := ffs.files[oldpath]; ok {
+		delete(ffs.files, oldpath)
+		if old, ok := ffs.files[newpath]; ok {
+			old.inner.Close()
+		}
+		ffs.files[newpath] = state
+	}
+	return nil
+}
+
+func (ffs *FaultFS) MkdirAll(path string, perm os.FileMode) error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return ErrPowerLoss
+	}
+	return ffs.fs.MkdirAll(path, perm)
+}
+
+func (ffs *FaultFS) Stat(name string) (os.FileInfo, error) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return nil, ErrPowerLoss
+	}
+	return ffs.fs.Stat(name)
+}
+
+func (ffs *FaultFS) ReadDir(name string) ([]os.DirEntry, error) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	if ffs.crashed {
+		return nil, ErrPowerLoss
+	}
+	return ffs.fs.ReadDir(name)
+}
+
+// faultFile. handle file dari FaultFS.
+type faultFile struct {
+	ffs    *FaultFS
+	name   string
+	state  *faultFileState
+	sync   bool // file dibuka dengan O_SYNC
+	closed bool
+}
+
+func (f *faultFile) ReadAt(b []byte, off int64) (int, error) {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.ffs.crashed {
+		return 0, ErrPowerLoss
+	}
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	err := f.ffs.checkFailBlock(f.name, "read", off, len(b))
+	if err != nil {
+		return 0, err
+	}
+	return f.state.inner.ReadAt(b, off)
+}
+
+func (f *faultFile) WriteAt(b []byte, off int64) (int, error) {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	crash, err := f.ffs.beginOp()
+	if err != nil {
+		return 0, err
+	}
+	err = f.ffs.checkFailBlock(f.name, "write", off, len(b))
+	if err != nil {
+		return 0, err
+	}
+
+	rec, err := f.undoRecord(off, len(b))
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.state.inner.WriteAt(b, off)
+	if err != nil {
+		return n, err
+	}
+	if crash || (!f.sync && f.ffs.dropUnsynced) {
+		f.state.undo = append(f.state.undo, rec)
+	}
+	if crash {
+		// write ini yang kena power loss: sync atau tidak, write tidak selesai
+		err = f.ffs.crash()
+		if err != nil {
+			return 0, err
+		}
+		return 0, ErrPowerLoss
+	}
+	return n, nil
+}
+
+// undoRecord. simpan isi file di range [off, off+n) sebelum di write.
+func (f *faultFile) undoRecord(off int64, n int) (undoRecord, error) {
+	size, err := f.state.inner.Size()
+	if err != nil {
+		return undoRecord{}, err
+	}
+	rec := undoRecord{off: off, oldSize: size, newLen: n}
+	if off < size {
+		rec.old = make([]byte, min(int64(n), size-off))
+		_, err = f.state.inner.ReadAt(rec.old, off)
+		if err != nil && err != io.EOF {
+			return undoRecord{}, err
+		}
+	}
+	return rec, nil
+}
+
+func (f *faultFile) Size() (int64, error) {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.ffs.crashed {
+		return 0, ErrPowerLoss
+	}
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.state.inner.Size()
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	crash, err := f.ffs.beginOp()
+	if err != nil {
+		return err
+	}
+	if crash {
+		// truncate yang kena power loss tidak persist
+		err = f.ffs.crash()
+		if err != nil {
+			return err
+		}
+		return ErrPowerLoss
+	}
+
+	oldSize, err := f.state.inner.Size()
+	if err != nil {
+		return err
+	}
+	rec := undoRecord{off: size, oldSize: oldSize}
+	if size < oldSize {
+		rec.old = make([]byte, oldSize-size)
+		_, err = f.state.inner.ReadAt(rec.old, size)
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	err = f.state.inner.Truncate(size)
+	if err != nil {
+		return err
+	}
+	if !f.sync && f.ffs.dropUnsynced {
+		f.state.undo = append(f.state.undo, rec)
+	}
+	return nil
+}
+
+func (f *faultFile) Sync() error {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	crash, err := f.ffs.beginOp()
+	if err != nil {
+		return err
+	}
+	if crash {
+		err = f.ffs.crash()
+		if err != nil {
+			return err
+		}
+		return ErrPowerLoss
+	}
+	err = f.state.inner.Sync()
+	if err != nil {
+		return err
+	}
+	f.state.undo = nil // semua write sebelumnya sudah persist
+	return nil
+}
+
+func (f *faultFile) Close() error {
+	f.ffs.mu.Lock()
+	defer f.ffs.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	return nil
+}
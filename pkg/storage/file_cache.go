@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMaxOpenFiles = 256
+	blockLatchStripes   = 64
+)
+
+// ErrFileInUse. dikembalikan CloseFile kalau file masih dipakai goroutine lain.
+var ErrFileInUse = errors.New("file is in use")
+
+// diskFile. file yang sedang dibuka DiskManager. semua read/write pakai offset (pread/pwrite), jadi satu File aman dipakai banyak goroutine.
+type diskFile struct {
+	name     string
+	f        File
+	direct   bool                            // file dibuka dengan O_DIRECT
+	readOnly bool                            // database dibuka read-only, write return ErrReadOnly
+	pins     int                             // jumlah goroutine yang sedang pakai file. file cuma boleh ditutup kalau pins = 0
+	lruElem  *list.Element                   // posisi file di fileLRU, nil kalau file sedang dipakai
+	dirty    atomic.Bool                     // ada write yang belum di fsync
+	appendMu sync.Mutex                      // serialize Append (BlockLength + write block baru)
+	allocMu  sync.Mutex                      // serialize Allocate & Free (update space map)
+	reserved map[int]bool                    // block free yang jadi tujuan RelocateBlock, tidak dialokasi/di truncate. dilindungi allocMu
+	latches  [blockLatchStripes]sync.RWMutex // latch block (striped by blockNum) biar write block & checksum nya atomic terhadap read
+
+	mmapLatch sync.Mutex
+	mmap      *mmapFile // tidak nil kalau file diread lewat memory mapping
+}
+
+// getMmap. return mapping file, nil kalau file tidak di mmap.
+func (df *diskFile) getMmap() *mmapFile {
+	df.mmapLatch.Lock()
+	defer df.mmapLatch.Unlock()
+	return df.mmap
+}
+
+// blockLatch. return latch buat blockNum.
+func (df *diskFile) blockLatch(blockNum int) *sync.RWMutex {
+	return &df.latches[blockNum%blockLatchStripes]
+}
+
+// sync. fsync file kalau ada write yang belum di fsync.
+func (df *diskFile) sync() error {
+	if !df.dirty.Swap(false) {
+		return nil
+	}
+	err := df.f.Sync()
+	if err != nil {
+		df.dirty.Store(true)
+	}
+	return err
+}
+
+// close. fsync & close file.
+func (df *diskFile) close() error {
+	err := df.sync()
+	if m := df.getMmap(); m != nil {
+		err = errors.Join(err, m.close())
+	}
+	return errors.Join(err, df.f.Close())
+}
+
+// WithMaxOpenFiles. set jumlah maksimal file yang dibuka bersamaan. file yang least recently used ditutup kalau sudah penuh.
+func WithMaxOpenFiles(n int) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.maxOpenFiles = max(n, 1)
+	}
+}
+
+/*
+acquireFile. get opened file dengan nama filename (relatif terhadap dbDir) & pin file tsb biar tidak ditutup selama dipakai.
+jika file belum dibuka, file dibuka (dibuat kalau belum ada). kalau jumlah file yang dibuka sudah maxOpenFiles, file least recently used yang tidak dipakai ditutup dulu.
+setiap acquireFile harus diikuti releaseFile.
+*/
+func (dm *DiskManager) acquireFile(filename string) (*diskFile, error) {
+	dm.filesLatch.Lock()
+	for {
+		done, ok := dm.closingFiles[filename]
+		if !ok {
+			break
+		}
+		// handle lama file masih di fsync & close: tunggu, biar sync lewat handle baru tidak selesai sebelum write lewat handle lama persist
+		dm.filesLatch.Unlock()
+		<-done
+		dm.filesLatch.Lock()
+	}
+	df, evicted, err := dm.acquireFileLocked(filename)
+	dm.filesLatch.Unlock()
+
+	closeErr := dm.closeDetached(evicted) // fsync file yang di evict di luar filesLatch
+	if err != nil {
+		return nil, errors.Join(err, closeErr)
+	}
+	if closeErr != nil {
+		dm.releaseFile(df)
+		return nil, closeErr
+	}
+	return df, nil
+}
+
+// acquireFileLocked. lihat acquireFile. return juga file yang di evict, yang harus di close caller dengan closeDetached. caller harus hold dm.filesLatch.
+func (dm *DiskManager) acquireFileLocked(filename string) (*diskFile, []*diskFile, error) {
+	if df, ok := dm.openFiles[filename]; ok {
+		dm.removeFromLRU(df)
+		df.pins++
+		return df, nil, nil
+	}
+
+	var evicted []*diskFile
+	for len(dm.openFiles) >= dm.maxOpenFiles {
+		// evict file least recently used. kalau semua file sedang dipakai, batas maxOpenFiles dilewati sementara
+		back := dm.fileLRU.Back()
+		if back == nil {
+			break
+		}
+		df := back.Value.(*diskFile)
+		dm.detachFileLocked(df)
+		evicted = append(evicted, df)
+	}
+
+	if isBlockFile(filename) {
+		err := dm.registerFile(filename) // file block baru dicatat di manifest (& tablespace nya dicek) sebelum file dibuat
+		if err != nil {
+			return nil, evicted, err
+		}
+	}
+	file, direct, err := dm.openFile(filename)
+	if err != nil {
+		return nil, evicted, err
+	}
+	df := &diskFile{name: filename, f: file, direct: direct, readOnly: dm.readOnly, pins: 1}
+	if dm.mmapFiles[filename] {
+		// kalau storage backend tidak bisa di mmap, file diread biasa
+		df.mmap, _ = newMmapFile(file)
+	}
+	dm.openFiles[filename] = df
+	return df, evicted, nil
+}
+
+// releaseFile. unpin file. file yang tidak dipakai lagi masuk LRU & bisa ditutup.
+func (dm *DiskManager) releaseFile(df *diskFile) {
+	dm.filesLatch.Lock()
+	defer dm.filesLatch.Unlock()
+
+	df.pins--
+	if df.pins == 0 && dm.openFiles[df.name] == df {
+		df.lruElem = dm.fileLRU.PushFront(df)
+	}
+}
+
+// removeFromLRU. hapus file dari LRU. caller harus hold dm.filesLatch.
+func (dm *DiskManager) removeFromLRU(df *diskFile) {
+	if df.lruElem != nil {
+		dm.fileLRU.Remove(df.lruElem)
+		df.lruElem = nil
+	}
+}
+
+/*
+detachFileLocked. lepas file yang tidak dipakai dari openFiles & LRU. file nya di fsync & close closeDetached di luar filesLatch,
+sampai selesai acquireFile file yang sama menunggu (lihat closingFiles). caller harus hold dm.filesLatch.
+*/
+func (dm *DiskManager) detachFileLocked(df *diskFile) {
+	dm.removeFromLRU(df)
+	delete(dm.openFiles, df.name)
+	dm.closingFiles[df.name] = make(chan struct{})
+}
+
+// closeDetached. fsync & close file hasil detachFileLocked. caller tidak boleh hold dm.filesLatch.
+func (dm *DiskManager) closeDetached(files []*diskFile) error {
+	var errs []error
+	for _, df := range files {
+		err := df.close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close file %s: %w", df.name, err))
+		}
+		dm.filesLatch.Lock()
+		close(dm.closingFiles[df.name])
+		delete(dm.closingFiles, df.name)
+		dm.filesLatch.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// CloseFile. fsync & close file filename beserta file fork nya (.crc, .lsn, ...). file dibuka lagi otomatis kalau dipakai lagi.
+func (dm *DiskManager) CloseFile(filename string) error {
+	dm.filesLatch.Lock()
+	var files []*diskFile
+	for name, df := range dm.openFiles {
+		if name != filename && forkBase(name) != filename {
+			continue
+		}
+		if df.pins > 0 {
+			dm.filesLatch.Unlock()
+			return fmt.Errorf("%w: %s", ErrFileInUse, name)
+		}
+		files = append(files, df)
+	}
+	for _, df := range files {
+		dm.detachFileLocked(df)
+	}
+	dm.filesLatch.Unlock()
+	return dm.closeDetached(files)
+}
+
+// CloseAll. fsync & close semua file yang tidak sedang dipakai.
+func (dm *DiskManager) CloseAll() error {
+	dm.filesLatch.Lock()
+	var errs []error
+	var files []*diskFile
+	for _, df := range dm.openFiles {
+		if df.pins > 0 {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrFileInUse, df.name))
+			continue
+		}
+		files = append(files, df)
+	}
+	for _, df := range files {
+		dm.detachFileLocked(df)
+	}
+	dm.filesLatch.Unlock()
+	return errors.Join(append(errs, dm.closeDetached(files))...)
+}
+
+// Close. shutdown DiskManager: tunggu async I/O selesai, fsync & close semua file, lalu lepas lock database.
+func (dm *DiskManager) Close() error {
+	err := dm.closeIOEngine()
+	err = errors.Join(err, dm.CloseAll())
+	return errors.Join(err, dm.unlockDatabase())
+}
+
+// NumOpenFiles. return jumlah file yang sedang dibuka.
+func (dm *DiskManager) NumOpenFiles() int {
+	dm.filesLatch.Lock()
+	defer dm.filesLatch.Unlock()
+	return len(dm.openFiles)
+}
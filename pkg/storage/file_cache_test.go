@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCache(t *testing.T) {
+	ffs := NewFaultFS(NewMemFS(), 4096, 1)
+	dm, err := NewDiskManager("lintangdb_cache", 4096, WithVFS(ffs), WithMaxOpenFiles(4))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		page := NewPage(4096)
+		page.PutString(0, fmt.Sprintf("lintang%d", i))
+		assert.NoError(t, dm.Write(NewBlockID(fmt.Sprintf("table%d.db", i), 0), page))
+		assert.LessOrEqual(t, dm.NumOpenFiles(), 4)
+	}
+
+	// file yang sudah ditutup dibuka lagi pas dipakai
+	page := NewPage(4096)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, dm.Read(NewBlockID(fmt.Sprintf("table%d.db", i), 0), page))
+		assert.Equal(t, fmt.Sprintf("lintang%d", i), page.GetString(0))
+	}
+
+	// file yang sedang dipakai tidak boleh ditutup
+	df, err := dm.acquireFile("table9.db")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, dm.CloseFile("table9.db"), ErrFileInUse)
+	dm.releaseFile(df)
+	assert.NoError(t, dm.CloseFile("table9.db"))
+	dm.filesLatch.Lock()
+	for name := range dm.openFiles {
+		assert.NotEqual(t, "table9.db", forkBase(name)) // file fork (.crc, ...) ikut ditutup
+	}
+	dm.filesLatch.Unlock()
+
+	// file di fsync pas ditutup (evict / Close): tidak ada write yang hilang pas crash
+	assert.NoError(t, dm.Close())
+	assert.Equal(t, 0, dm.NumOpenFiles())
+	assert.NoError(t, ffs.Crash())
+	ffs.Restart()
+
+	dm, err = NewDiskManager("lintangdb_cache", 4096, WithVFS(ffs), WithMaxOpenFiles(4))
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, dm.Read(NewBlockID(fmt.Sprintf("table%d.db", i), 0), page))
+		assert.Equal(t, fmt.Sprintf("lintang%d", i), page.GetString(0))
+	}
+}
+
+func TestFileCacheConcurrentEviction(t *testing.T) {
+	ffs := NewFaultFS(NewMemFS(), 4096, 1)
+	dm, err := NewDiskManager("lintangdb_cache_evict", 4096, WithVFS(ffs), WithMaxOpenFiles(2))
+	assert.NoError(t, err)
+
+	// file di evict & dibuka lagi bolak-balik: write yang sudah di SyncFile tidak hilang pas crash
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				filename := fmt.Sprintf("table%d.db", (g+i)%6)
+				page := NewPage(4096)
+				page.PutString(0, fmt.Sprintf("lintang%d-%d", g, i))
+				assert.NoError(t, dm.Write(NewBlockID(filename, g), page))
+				assert.NoError(t, dm.SyncFile(filename))
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.NoError(t, ffs.Crash())
+	ffs.Restart()
+
+	dm, err = NewDiskManager("lintangdb_cache_evict", 4096, WithVFS(ffs))
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	for g := 0; g < 4; g++ {
+		for i := 44; i < 50; i++ { // write terakhir goroutine g ke tiap file
+			filename := fmt.Sprintf("table%d.db", (g+i)%6)
+			assert.NoError(t, dm.Read(NewBlockID(filename, g), page))
+			assert.Equal(t, fmt.Sprintf("lintang%d-%d", g, i), page.GetString(0))
+		}
+	}
+	assert.NoError(t, dm.Close())
+}
+
+// blockingSyncFS. MemFS yang fsync file blockName nya menunggu release.
+type blockingSyncFS struct {
+	*MemFS
+	blockName string
+	syncing   chan struct{}
+	release   chan struct{}
+}
+
+type blockingSyncFile struct {
+	File
+	fs *blockingSyncFS
+}
+
+func (fs *blockingSyncFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.MemFS.OpenFile(name, flag, perm)
+	if err != nil || filepath.Base(name) != fs.blockName {
+		return f, err
+	}
+	return &blockingSyncFile{File: f, fs: fs}, nil
+}
+
+func (f *blockingSyncFile) Sync() error {
+	select {
+	case f.fs.syncing <- struct{}{}:
+		<-f.fs.release
+	default:
+	}
+	return f.File.Sync()
+}
+
+func TestSyncOutsideFilesLatch(t *testing.T) {
+	fs := &blockingSyncFS{MemFS: NewMemFS(), blockName: "slow.db", syncing: make(chan struct{}), release: make(chan struct{})}
+	dm, err := NewDiskManager("lintangdb_sync", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("slow.db", 0), page))
+
+	synced := make(chan error)
+	go func() {
+		synced <- dm.Sync()
+	}()
+	<-fs.syncing
+
+	// file lain tetap bisa dibuka & dipakai selama fsync berjalan
+	read := make(chan error)
+	go func() {
+		read <- dm.Write(NewBlockID("other.db", 0), page)
+	}()
+	select {
+	case err = <-read:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("acquireFile blocked by Sync")
+	}
+	close(fs.release)
+	assert.NoError(t, <-synced)
+	assert.NoError(t, dm.Close())
+}
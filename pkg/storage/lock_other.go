@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package storage
+
+// Lock. OS ini belum support flock, database dibuka tanpa lock.
+func (OSFS) Lock(name string, exclusive bool) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseLock(t *testing.T) {
+	os.RemoveAll("lintangdb_lock")
+	defer os.RemoveAll("lintangdb_lock")
+
+	for name, opts := range map[string][]DiskManagerOption{
+		"flock": nil,
+		"memfs": {WithVFS(NewMemFS())},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dm, err := NewDiskManager("lintangdb_lock", 4096, opts...)
+			assert.NoError(t, err)
+			assert.True(t, dm.IsNew())
+			page := NewPage(4096)
+			page.PutString(0, "lintang")
+			assert.NoError(t, dm.Write(NewBlockID("table.db", 0), page))
+
+			// database sudah dibuka read-write: open kedua (read-write / read-only) ditolak
+			_, err = NewDiskManager("lintangdb_lock", 4096, opts...)
+			assert.ErrorIs(t, err, ErrDatabaseLocked)
+			_, err = NewDiskManager("lintangdb_lock", 4096, append(opts, WithReadOnly(true))...)
+			assert.ErrorIs(t, err, ErrDatabaseLocked)
+			assert.NoError(t, dm.Close())
+
+			// beberapa tool inspeksi read-only boleh membuka database bersamaan
+			ro1, err := NewDiskManager("lintangdb_lock", 4096, append(opts, WithReadOnly(true))...)
+			assert.NoError(t, err)
+			ro2, err := NewDiskManager("lintangdb_lock", 4096, append(opts, WithReadOnly(true))...)
+			assert.NoError(t, err)
+			assert.True(t, ro1.IsReadOnly())
+
+			assert.NoError(t, ro1.Read(NewBlockID("table.db", 0), page))
+			assert.Equal(t, "lintang", page.GetString(0))
+			assert.ErrorIs(t, ro1.Write(NewBlockID("table.db", 0), page), ErrReadOnly)
+			_, err = ro1.Append("table.db")
+			assert.ErrorIs(t, err, ErrReadOnly)
+			assert.ErrorIs(t, ro1.WriteAsync(NewBlockID("table.db", 0), page).Wait(), ErrReadOnly)
+			_, err = ro1.BlockLength("missing.db") // file yang belum ada tidak dibuat
+			assert.ErrorIs(t, err, os.ErrNotExist)
+
+			_, err = NewDiskManager("lintangdb_lock", 4096, opts...)
+			assert.ErrorIs(t, err, ErrDatabaseLocked)
+			assert.NoError(t, ro1.Close())
+			assert.NoError(t, ro2.Close())
+
+			// lock dilepas pas Close
+			dm, err = NewDiskManager("lintangdb_lock", 4096, opts...)
+			assert.NoError(t, err)
+			assert.NoError(t, dm.Close())
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lintang-b-s/go-simpledb/pkg"
+)
+
+/*
+manifest (superblock) database. ditulis pas database dibuat & divalidasi tiap NewDiskManager. mencatat versi format file, block size, tablespace & file-file block database.
+manifest selalu ditulis ulang lewat file sementara + rename, jadi tidak pernah torn.
+
+format file MANIFEST:
+[magic (4)] [checksum (4)] [format version (4)] [block size (4)] [created at unix nano (8)] [jumlah file (4)]
+lalu per file: [name length (2)] [name] [block size (4)] [tablespace length (2)] [tablespace]
+lalu [jumlah tablespace (4)] & per tablespace: [name length (2)] [name] [dir length (2)] [dir]
+format version 1 belum punya block size per file (semua file pakai block size database), format version 2 belum punya tablespace (semua file di dbDir).
+*/
+const (
+	manifestFile  = "MANIFEST"
+	manifestMagic = 0x4d424453 // "SDBM"
+
+	// FormatVersion. versi format file database yang ditulis DiskManager ini.
+	FormatVersion uint32 = 3
+)
+
+var (
+	ErrBlockSizeMismatch = errors.New("block size does not match database")
+	ErrUnsupportedFormat = errors.New("unsupported database format version")
+	ErrCorruptManifest   = errors.New("corrupt database manifest")
+)
+
+// UpgradeFunc. upgrade database dengan format fromVersion ke fromVersion+1.
+type UpgradeFunc func(dm *DiskManager, fromVersion uint32) error
+
+// formatUpgrades. upgrade bawaan per versi format: formatUpgrades[v] upgrade database versi v ke v+1.
+var formatUpgrades = map[uint32]UpgradeFunc{
+	// v1 -> v2: block size per file di manifest. file lama pakai block size database (sudah diisi pas decode manifest v1).
+	1: func(dm *DiskManager, fromVersion uint32) error { return nil },
+	// v2 -> v3: tablespace. file lama ada di tablespace default (sudah diisi pas decode manifest v2).
+	2: func(dm *DiskManager, fromVersion uint32) error { return nil },
+}
+
+// WithUpgradeHook. fn dipanggil untuk tiap versi format lama (setelah upgrade bawaan versi tsb) pas database dengan format lama dibuka.
+func WithUpgradeHook(fn UpgradeFunc) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.upgradeHooks = append(dm.upgradeHooks, fn)
+	}
+}
+
+// manifest. isi file MANIFEST.
+type manifest struct {
+	mu            sync.Mutex
+	formatVersion uint32
+	blockSize     int
+	createdAt     time.Time
+	files         map[string]fileEntry // file block database
+	tablespaces   map[string]string    // nama tablespace -> directory (selain tablespace default)
+}
+
+// fileEntry. info file block di manifest.
+type fileEntry struct {
+	blockSize  int
+	tablespace string
+}
+
+func newManifest(blockSize int) *manifest {
+	return &manifest{formatVersion: FormatVersion, blockSize: blockSize, createdAt: time.Now(),
+		files: make(map[string]fileEntry), tablespaces: make(map[string]string)}
+}
+
+// fileNames. return nama file di manifest, urut berdasarkan nama. caller harus hold m.mu.
+func (m *manifest) fileNames() []string {
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *manifest) encode() []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, manifestMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // checksum
+	buf = binary.LittleEndian.AppendUint32(buf, m.formatVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(m.blockSize))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(m.createdAt.UnixNano()))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(m.files)))
+	for _, name := range m.fileNames() {
+		buf = appendString(buf, name)
+		if m.formatVersion >= 2 {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(m.files[name].blockSize))
+		}
+		if m.formatVersion >= 3 {
+			buf = appendString(buf, m.files[name].tablespace)
+		}
+	}
+	if m.formatVersion >= 3 {
+		names := make([]string, 0, len(m.tablespaces))
+		for name := range m.tablespaces {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(names)))
+		for _, name := range names {
+			buf = appendString(buf, name)
+			buf = appendString(buf, m.tablespaces[name])
+		}
+	}
+	binary.LittleEndian.PutUint32(buf[4:], pageChecksum(buf))
+	return buf
+}
+
+func decodeManifest(buf []byte) (*manifest, error) {
+	if len(buf) < 28 || binary.LittleEndian.Uint32(buf) != manifestMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorruptManifest)
+	}
+	checksum := binary.LittleEndian.Uint32(buf[4:])
+	binary.LittleEndian.PutUint32(buf[4:], 0)
+	if pageChecksum(buf) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptManifest)
+	}
+	m := &manifest{
+		formatVersion: binary.LittleEndian.Uint32(buf[8:]),
+		blockSize:     int(binary.LittleEndian.Uint32(buf[12:])),
+		createdAt:     time.Unix(0, int64(binary.LittleEndian.Uint64(buf[16:]))),
+		files:         make(map[string]fileEntry),
+		tablespaces:   make(map[string]string),
+	}
+	numFiles := int(binary.LittleEndian.Uint32(buf[24:]))
+	buf = buf[28:]
+	var ok bool
+	for i := 0; i < numFiles; i++ {
+		var name string
+		entry := fileEntry{blockSize: m.blockSize, tablespace: DefaultTablespace}
+		name, buf, ok = readString(buf)
+		if ok && m.formatVersion >= 2 {
+			ok = len(buf) >= 4
+			if ok {
+				entry.blockSize = int(binary.LittleEndian.Uint32(buf))
+				buf = buf[4:]
+			}
+		}
+		if ok && m.formatVersion >= 3 {
+			entry.tablespace, buf, ok = readString(buf)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated file list", ErrCorruptManifest)
+		}
+		m.files[name] = entry
+	}
+	if m.formatVersion >= 3 {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("%w: truncated tablespace list", ErrCorruptManifest)
+		}
+		numTablespaces := int(binary.LittleEndian.Uint32(buf))
+		buf = buf[4:]
+		for i := 0; i < numTablespaces; i++ {
+			var name, dir string
+			name, buf, ok = readString(buf)
+			if ok {
+				dir, buf, ok = readString(buf)
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: truncated tablespace list", ErrCorruptManifest)
+			}
+			m.tablespaces[name] = dir
+		}
+	}
+	return m, nil
+}
+
+// appendString. append [length (2)] [s] ke buf.
+func appendString(buf []byte, s string) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// readString. read string yang ditulis appendString dari awal buf, return sisa buf. return false kalau buf terpotong.
+func readString(buf []byte) (string, []byte, bool) {
+	if len(buf) < 2 || len(buf) < 2+int(binary.LittleEndian.Uint16(buf)) {
+		return "", buf, false
+	}
+	n := int(binary.LittleEndian.Uint16(buf))
+	return string(buf[2 : 2+n]), buf[2+n:], true
+}
+
+// writeManifest. tulis manifest ke file MANIFEST lewat file sementara + rename. caller harus hold m.mu (atau manifest belum dipakai goroutine lain).
+func (dm *DiskManager) writeManifest(m *manifest) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot write manifest", ErrReadOnly)
+	}
+	tmpPath := dm.dbFilePath(manifestFile + ".tmp")
+	f, err := dm.fs.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(m.encode(), 0)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return dm.fs.Rename(tmpPath, dm.dbFilePath(manifestFile))
+}
+
+// readManifest. read file MANIFEST, return fs.ErrNotExist kalau belum ada.
+func (dm *DiskManager) readManifest() (*manifest, error) {
+	f, err := dm.fs.OpenFile(dm.dbFilePath(manifestFile), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	_, err = f.ReadAt(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeManifest(buf)
+}
+
+/*
+openManifest. dipanggil NewDiskManager. kalau dbDir kosong, database baru: manifest dibuat & isNew = true.
+kalau dbDir sudah berisi file tapi belum punya manifest (database dari versi lama), manifest dibuat dari file yang ada.
+kalau manifest ada, block size harus sama & format lama diupgrade.
+*/
+func (dm *DiskManager) openManifest() error {
+	m, err := dm.readManifest()
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err := dm.fs.ReadDir(dm.dbDir)
+		if err != nil {
+			return err
+		}
+		if dm.blockSize == 0 {
+			dm.blockSize = pkg.DEFAULT_PAGE_SIZE
+		}
+		err = validateBlockSize(dm.blockSize)
+		if err != nil {
+			return err
+		}
+		m = newManifest(dm.blockSize)
+		numFiles := 0
+		for _, entry := range entries {
+			if entry.Name() == lockFile || entry.Name() == manifestFile+".tmp" {
+				continue
+			}
+			numFiles++
+			if !entry.IsDir() && isBlockFile(entry.Name()) {
+				m.files[entry.Name()] = fileEntry{blockSize: dm.blockSize, tablespace: DefaultTablespace}
+			}
+		}
+		dm.isNew = numFiles == 0
+		dm.manifest = m
+		if dm.readOnly {
+			return nil // database lama tanpa manifest, manifest cuma di memori
+		}
+		return dm.writeManifest(m)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dm.blockSize == 0 {
+		dm.blockSize = m.blockSize // pakai block size database yang tercatat di manifest
+	}
+	if m.blockSize != dm.blockSize {
+		return fmt.Errorf("%w: database %s has block size %d, opened with %d", ErrBlockSizeMismatch, dm.dbDir, m.blockSize, dm.blockSize)
+	}
+	if m.formatVersion > FormatVersion {
+		return fmt.Errorf("%w: database %s has format version %d, this version supports up to %d", ErrUnsupportedFormat, dm.dbDir, m.formatVersion, FormatVersion)
+	}
+	dm.manifest = m
+	if dm.readOnly && m.formatVersion < FormatVersion {
+		return fmt.Errorf("%w: database %s needs upgrade from format version %d", ErrReadOnly, dm.dbDir, m.formatVersion)
+	}
+	for m.formatVersion < FormatVersion {
+		from := m.formatVersion
+		if upgrade, ok := formatUpgrades[from]; ok {
+			err = upgrade(dm, from)
+			if err != nil {
+				return fmt.Errorf("failed to upgrade database from format version %d: %w", from, err)
+			}
+		}
+		for _, hook := range dm.upgradeHooks {
+			err = hook(dm, from)
+			if err != nil {
+				return fmt.Errorf("upgrade hook failed for format version %d: %w", from, err)
+			}
+		}
+		m.formatVersion = from + 1
+		err = dm.writeManifest(m) // versi dicatat tiap step, upgrade yang gagal di tengah dilanjutkan dari versi terakhir
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerFile. catat file block baru di manifest dengan block size & tablespace yang dikonfigurasi buat file tsb. file yang sudah tercatat tidak diubah.
+func (dm *DiskManager) registerFile(filename string) error {
+	return dm.registerFileEntry(filename, fileEntry{})
+}
+
+// registerFileEntry. catat file block baru di manifest. field entry yang kosong diisi block size / tablespace yang dikonfigurasi buat file tsb.
+// return error kalau file sudah tercatat dengan block size / tablespace lain, atau tablespace nya tidak ada.
+func (dm *DiskManager) registerFileEntry(filename string, entry fileEntry) error {
+	m := dm.manifest
+	if m == nil || dm.readOnly || filename == manifestFile+".tmp" {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.files[filename]; ok {
+		if entry.blockSize != 0 && existing.blockSize != entry.blockSize {
+			return fmt.Errorf("%w: file %s already exists with block size %d", ErrBlockSizeMismatch, filename, existing.blockSize)
+		}
+		if entry.tablespace != "" && existing.tablespace != entry.tablespace {
+			return fmt.Errorf("%w: file %s already exists in tablespace %s", ErrTablespaceMismatch, filename, existing.tablespace)
+		}
+		return nil
+	}
+	if entry.blockSize == 0 {
+		entry.blockSize = dm.configuredBlockSize(filename)
+	}
+	if entry.tablespace == "" {
+		entry.tablespace = dm.fileTablespaceLocked(filename)
+	}
+	if _, ok := m.tablespaces[entry.tablespace]; !ok && entry.tablespace != DefaultTablespace {
+		return fmt.Errorf("%w: %s (file %s)", ErrTablespaceNotFound, entry.tablespace, filename)
+	}
+	m.files[filename] = entry
+	err := dm.writeManifest(m)
+	if err != nil {
+		delete(m.files, filename)
+	}
+	return err
+}
+
+// Files. return file block yang tercatat di manifest database, urut berdasarkan nama.
+func (dm *DiskManager) Files() []string {
+	dm.manifest.mu.Lock()
+	defer dm.manifest.mu.Unlock()
+	return dm.manifest.fileNames()
+}
+
+// FormatVersion. return versi format file database yang sedang dibuka.
+func (dm *DiskManager) FormatVersion() uint32 {
+	dm.manifest.mu.Lock()
+	defer dm.manifest.mu.Unlock()
+	return dm.manifest.formatVersion
+}
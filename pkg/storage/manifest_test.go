@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_manifest", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.True(t, dm.IsNew())
+	assert.Equal(t, FormatVersion, dm.FormatVersion())
+
+	_, err = dm.Append("table.db")
+	assert.NoError(t, err)
+	_, err = dm.Append("index.db")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"index.db", "table.db"}, dm.Files())
+	assert.NoError(t, dm.Close())
+
+	dm, err = NewDiskManager("lintangdb_manifest", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.False(t, dm.IsNew())
+	assert.Equal(t, []string{"index.db", "table.db"}, dm.Files())
+
+	// block size beda dengan yang dipakai pas database dibuat
+	assert.NoError(t, dm.Close())
+	_, err = NewDiskManager("lintangdb_manifest", 8192, WithVFS(fs))
+	assert.ErrorIs(t, err, ErrBlockSizeMismatch)
+
+	// manifest corrupt
+	f, err := fs.OpenFile(dm.dbFilePath(manifestFile), os.O_RDWR, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, 20)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	_, err = NewDiskManager("lintangdb_manifest", 4096, WithVFS(fs))
+	assert.ErrorIs(t, err, ErrCorruptManifest)
+}
+
+func TestManifestUpgrade(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_manifest_upgrade", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Close())
+
+	// simulasi database dengan format versi lama
+	dm.manifest.formatVersion = 0
+	assert.NoError(t, dm.writeManifest(dm.manifest))
+
+	var upgraded []uint32
+	dm, err = NewDiskManager("lintangdb_manifest_upgrade", 4096, WithVFS(fs), WithUpgradeHook(func(dm *DiskManager, from uint32) error {
+		upgraded = append(upgraded, from)
+		return nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0, 1, 2}, upgraded) // 0 -> 1 -> 2 -> 3
+	assert.Equal(t, FormatVersion, dm.FormatVersion())
+
+	// format yang lebih baru dari versi ini ditolak
+	assert.NoError(t, dm.Close())
+	dm.manifest.formatVersion = FormatVersion + 1
+	assert.NoError(t, dm.writeManifest(dm.manifest))
+	_, err = NewDiskManager("lintangdb_manifest_upgrade", 4096, WithVFS(fs))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	// database lama tanpa manifest: manifest dibuat dari file yang sudah ada
+	assert.NoError(t, fs.Remove(dm.dbFilePath(manifestFile)))
+	dm, err = NewDiskManager("lintangdb_manifest_upgrade", 4096, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.False(t, dm.IsNew())
+	assert.Equal(t, FormatVersion, dm.FormatVersion())
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemFS. VFS in-memory. semua file hilang kalau proses selesai, buat test & database ephemeral.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	dirs  map[string]bool
+	locks memLocks
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(map[string]*memFile),
+		dirs:  map[string]bool{".": true, "/": true},
+	}
+}
+
+// memFile. isi file di memori. satu memFile bisa dibuka berkali-kali (semua handle share data yang sama).
+type memFile struct {
+	mu      sync.RWMutex
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+func cleanPath(name string) string {
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = cleanPath(name)
+	f, ok := m.files[name]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if !m.dirs[path.Dir(name)] {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		f = &memFile{name: name, modTime: time.Now()}
+		m.files[name] = f
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if flag&os.O_TRUNC != 0 {
+		f.Truncate(0)
+	}
+	return &memHandle{f: f}, nil
+}
+
+// Lock. lock file name in-process (cuma berlaku buat DiskManager yang pakai MemFS yang sama).
+func (m *MemFS) Lock(name string, exclusive bool) (func() error, error) {
+	return m.locks.lock(name, exclusive)
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = cleanPath(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if m.dirs[name] {
+		for other := range m.files {
+			if path.Dir(other) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+			}
+		}
+		delete(m.dirs, name)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldpath, newpath = cleanPath(oldpath), cleanPath(newpath)
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	delete(m.files, oldpath)
+	f.name = newpath
+	m.files[newpath] = f
+	return nil
+}
+
+func (m *MemFS) MkdirAll(dir string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir = cleanPath(dir)
+	for {
+		m.dirs[dir] = true
+		parent := path.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = cleanPath(name)
+	if f, ok := m.files[name]; ok {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		return &memFileInfo{name: path.Base(name), size: int64(len(f.data)), modTime: f.modTime}, nil
+	}
+	if m.dirs[name] {
+		return &memFileInfo{name: path.Base(name), isDir: true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = cleanPath(name)
+	if !m.dirs[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []os.DirEntry
+	for filename, f := range m.files {
+		if path.Dir(filename) == name {
+			f.mu.RLock()
+			entries = append(entries, fs.FileInfoToDirEntry(&memFileInfo{name: path.Base(filename), size: int64(len(f.data)), modTime: f.modTime}))
+			f.mu.RUnlock()
+		}
+	}
+	for dir := range m.dirs {
+		if dir != name && path.Dir(dir) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(&memFileInfo{name: path.Base(dir), isDir: true}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (f *memFile) Truncate(size int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if size <= int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	f.modTime = time.Now()
+}
+
+// memHandle. handle dari memFile yang dibuka lewat MemFS.OpenFile.
+type memHandle struct {
+	f      *memFile
+	closed bool
+}
+
+func (h *memHandle) ReadAt(b []byte, off int64) (int, error) {
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	h.f.mu.RLock()
+	defer h.f.mu.RUnlock()
+	if off >= int64(len(h.f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, h.f.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *memHandle) WriteAt(b []byte, off int64) (int, error) {
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	end := off + int64(len(b))
+	if end > int64(len(h.f.data)) {
+		h.f.data = append(h.f.data, make([]byte, end-int64(len(h.f.data)))...)
+	}
+	copy(h.f.data[off:], b)
+	h.f.modTime = time.Now()
+	return len(b), nil
+}
+
+func (h *memHandle) Size() (int64, error) {
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	h.f.mu.RLock()
+	defer h.f.mu.RUnlock()
+	return int64(len(h.f.data)), nil
+}
+
+func (h *memHandle) Truncate(size int64) error {
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.f.Truncate(size)
+	return nil
+}
+
+func (h *memHandle) Sync() error {
+	if h.closed {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+func (h *memHandle) Close() error {
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.closed = true
+	return nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.isDir }
+func (fi *memFileInfo) Sys() any           { return nil }
+func (fi *memFileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
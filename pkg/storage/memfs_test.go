@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemFSDiskManager(t *testing.T) {
+	memFS := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_mem", 4096, WithVFS(memFS))
+	assert.NoError(t, err)
+
+	_, err = os.Stat("lintangdb_mem")
+	assert.True(t, os.IsNotExist(err)) // tidak ada file yang dibuat di OS
+
+	for i := 0; i < 3; i++ {
+		blockID, err := dm.Append("test.db")
+		assert.NoError(t, err)
+		assert.Equal(t, i, blockID.GetBlockNum())
+
+		page := NewPage(4096)
+		page.PutString(0, "lintang")
+		page.PutInt(100, i)
+		err = dm.WriteBlocks([]BlockWrite{{BlockID: blockID, Page: page}})
+		assert.NoError(t, err)
+	}
+
+	n, err := dm.BlockLength("test.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// reopen database di MemFS yang sama
+	assert.NoError(t, dm.Close())
+	dm, err = NewDiskManager("lintangdb_mem", 4096, WithVFS(memFS))
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		page := NewPage(4096)
+		err = dm.Read(NewBlockID("test.db", i), page)
+		assert.NoError(t, err)
+		assert.Equal(t, "lintang", page.GetString(0))
+		assert.Equal(t, i, page.GetInt(100))
+	}
+
+	entries, err := memFS.ReadDir("lintangdb_mem")
+	assert.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"MANIFEST", "doublewrite.buf", "test.db", "test.db.crc"}, names)
+
+	err = memFS.Remove("lintangdb_mem/test.db")
+	assert.NoError(t, err)
+	_, err = memFS.Stat("lintangdb_mem/test.db")
+	assert.True(t, os.IsNotExist(err))
+}
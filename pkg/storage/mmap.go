@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WithMmap. file-file ini (biasanya tabel referensi yang besar & jarang diupdate) diread lewat memory mapping, bukan pread per block.
+func WithMmap(filenames ...string) DiskManagerOption {
+	return func(dm *DiskManager) {
+		for _, filename := range filenames {
+			dm.mmapFiles[filename] = true
+		}
+	}
+}
+
+// mmapFile. mapping read-only satu file. diremap kalau file bertambah besar.
+// reader yang masih pegang mapping setelah close (UnmapFile bersamaan) read lewat pread, mapping tidak dibuat lagi.
+type mmapFile struct {
+	mu     sync.RWMutex
+	f      File
+	data   []byte
+	closed bool
+}
+
+// fdFile. File yang punya file descriptor OS (bisa di mmap).
+type fdFile interface {
+	Fd() uintptr
+}
+
+func newMmapFile(f File) (*mmapFile, error) {
+	if _, ok := f.(fdFile); !ok {
+		return nil, fmt.Errorf("mmap: storage backend file does not have a file descriptor")
+	}
+	m := &mmapFile{f: f}
+	err := m.remap()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// remap. mmap ulang file dengan ukuran file sekarang. mapping yang sudah diclose tidak diremap. caller harus hold m.mu (write).
+func (m *mmapFile) remap() error {
+	if m.closed {
+		return nil
+	}
+	size, err := m.f.Size()
+	if err != nil {
+		return err
+	}
+	if int64(len(m.data)) == size {
+		return nil
+	}
+	if m.data != nil {
+		err = munmap(m.data)
+		if err != nil {
+			return err
+		}
+		m.data = nil
+	}
+	if size == 0 {
+		return nil
+	}
+	m.data, err = mmap(m.f.(fdFile).Fd(), int(size))
+	return err
+}
+
+// ensure. pastikan range [off, off+n) ada di mapping, remap kalau file sudah bertambah besar. caller harus hold m.mu (read).
+func (m *mmapFile) ensure(off int64, n int) error {
+	if off+int64(n) <= int64(len(m.data)) {
+		return nil
+	}
+	m.mu.RUnlock()
+	m.mu.Lock()
+	err := m.remap()
+	m.mu.Unlock()
+	m.mu.RLock()
+	return err
+}
+
+// readAt. copy isi file di offset off dari mapping ke b. semantik sama dengan ReadAt.
+func (m *mmapFile) readAt(b []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	err := m.ensure(off, len(b))
+	if err != nil {
+		return 0, err
+	}
+	if m.closed {
+		return m.f.ReadAt(b, off)
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, m.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// view. panggil fn dengan slice mapping [off, off+n) tanpa copy. slice hanya valid selama fn berjalan.
+func (m *mmapFile) view(off int64, n int, fn func([]byte) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	err := m.ensure(off, n)
+	if err != nil {
+		return err
+	}
+	if m.closed {
+		b := make([]byte, n)
+		_, err = m.f.ReadAt(b, off)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	}
+	if off+int64(n) > int64(len(m.data)) {
+		return io.EOF
+	}
+	return fn(m.data[off : off+int64(n) : off+int64(n)])
+}
+
+func (m *mmapFile) close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	if m.data == nil {
+		return nil
+	}
+	err := munmap(m.data)
+	m.data = nil
+	return err
+}
+
+// MapFile. mulai read file filename lewat memory mapping.
+func (dm *DiskManager) MapFile(filename string) error {
+	df, err := dm.acquireFile(filename)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	dm.setMmapFile(filename, true) // file tetap di mmap kalau ditutup lalu dibuka lagi
+	df.mmapLatch.Lock()
+	defer df.mmapLatch.Unlock()
+	if df.mmap != nil {
+		return nil
+	}
+	df.mmap, err = newMmapFile(df.f)
+	return err
+}
+
+// UnmapFile. stop read file filename lewat memory mapping, read kembali pakai pread.
+func (dm *DiskManager) UnmapFile(filename string) error {
+	df, err := dm.acquireFile(filename)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	dm.setMmapFile(filename, false)
+	df.mmapLatch.Lock()
+	defer df.mmapLatch.Unlock()
+	if df.mmap == nil {
+		return nil
+	}
+	err = df.mmap.close()
+	df.mmap = nil
+	return err
+}
+
+// setMmapFile. set apakah file filename diread lewat memory mapping.
+func (dm *DiskManager) setMmapFile(filename string, enabled bool) {
+	dm.filesLatch.Lock()
+	defer dm.filesLatch.Unlock()
+	if enabled {
+		dm.mmapFiles[filename] = true
+	} else {
+		delete(dm.mmapFiles, filename)
+	}
+}
+
+// ReadView. zero-copy read block dari file yang di mmap: fn dipanggil dengan isi block langsung dari mapping.
+// slice hanya valid selama fn berjalan & tidak boleh diubah. file yang tidak di mmap (atau dicompress / dienkripsi) diread ke page biasa.
+func (dm *DiskManager) ReadView(blockID BlockID, fn func(contents []byte) error) error {
+	df, err := dm.acquireFile(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	transformed, err := dm.isTransformedFile(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+	m := df.getMmap()
+	if m == nil || transformed {
+		page := NewPage(dm.FileBlockSize(blockID.GetFilename()))
+		err = dm.Read(blockID, page)
+		if err != nil {
+			return err
+		}
+		return fn(page.Contents())
+	}
+
+	latch := df.blockLatch(blockID.GetBlockNum())
+	latch.RLock()
+	defer latch.RUnlock()
+	blockSize := dm.FileBlockSize(blockID.GetFilename())
+	return m.view(int64(blockID.GetBlockNum()*blockSize), blockSize, func(contents []byte) error {
+		if dm.verifyChecksum {
+			err := dm.validateChecksum(blockID, contents)
+			if err != nil {
+				return err
+			}
+		}
+		return fn(contents)
+	})
+}
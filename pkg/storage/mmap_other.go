@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package storage
+
+import "errors"
+
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+func mmap(fd uintptr, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(b []byte) error {
+	return errMmapUnsupported
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMmapRead(t *testing.T) {
+	os.RemoveAll("lintangdb_mmap")
+	defer os.RemoveAll("lintangdb_mmap")
+
+	dm, err := NewDiskManager("lintangdb_mmap", 4096, WithMmap("cold.db"))
+	assert.NoError(t, err)
+	defer dm.Close()
+
+	for i := 0; i < 4; i++ {
+		page := NewPage(4096)
+		page.PutString(0, fmt.Sprintf("lintang%d", i))
+		assert.NoError(t, dm.Write(NewBlockID("cold.db", i), page))
+	}
+
+	df, err := dm.acquireFile("cold.db")
+	assert.NoError(t, err)
+	defer dm.releaseFile(df)
+	assert.NotNil(t, df.getMmap())
+
+	page := NewPage(4096)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, dm.Read(NewBlockID("cold.db", i), page))
+		assert.Equal(t, fmt.Sprintf("lintang%d", i), page.GetString(0))
+	}
+
+	// file bertambah besar: mapping diremap
+	blockID, err := dm.Append("cold.db")
+	assert.NoError(t, err)
+	page.PutString(0, "birda")
+	assert.NoError(t, dm.Write(blockID, page))
+
+	err = dm.ReadView(blockID, func(contents []byte) error {
+		assert.Equal(t, "birda", NewPageFromByteSlice(contents).GetString(0))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dm.UnmapFile("cold.db"))
+	assert.Nil(t, df.getMmap())
+	assert.NoError(t, dm.Read(NewBlockID("cold.db", 2), page))
+	assert.Equal(t, "lintang2", page.GetString(0))
+
+	assert.NoError(t, dm.MapFile("cold.db"))
+	assert.NoError(t, dm.Read(NewBlockID("cold.db", 3), page))
+	assert.Equal(t, "lintang3", page.GetString(0))
+
+	// file yang di MemFS tidak bisa di mmap
+	memDM, err := NewDiskManager("lintangdb_mmap", 4096, WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+	assert.Error(t, memDM.MapFile("cold.db"))
+	assert.NoError(t, memDM.Close())
+}
+
+func TestUnmapFileConcurrentRead(t *testing.T) {
+	os.RemoveAll("lintangdb_mmap_unmap")
+	defer os.RemoveAll("lintangdb_mmap_unmap")
+
+	dm, err := NewDiskManager("lintangdb_mmap_unmap", 4096, WithMmap("cold.db"))
+	assert.NoError(t, err)
+	defer dm.Close()
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("cold.db", 0), page))
+
+	// reader yang masih pegang mapping lama setelah UnmapFile read lewat pread & mapping tidak dibuat lagi
+	df, err := dm.acquireFile("cold.db")
+	assert.NoError(t, err)
+	m := df.getMmap()
+	dm.releaseFile(df)
+	assert.NoError(t, dm.UnmapFile("cold.db"))
+	_, err = dm.Append("cold.db")
+	assert.NoError(t, err)
+	b := make([]byte, 4096)
+	_, err = m.readAt(b, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "lintang", NewPageFromByteSlice(b).GetString(0))
+	assert.Empty(t, len(m.data), "mapping yang sudah diclose tidak dibuat lagi")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page := NewPage(4096)
+			for j := 0; j < 200; j++ {
+				assert.NoError(t, dm.Read(NewBlockID("cold.db", 0), page))
+				assert.Equal(t, "lintang", page.GetString(0))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, dm.MapFile("cold.db"))
+		assert.NoError(t, dm.UnmapFile("cold.db"))
+	}
+	wg.Wait()
+}
@@ -10,9 +10,10 @@ type Page struct {
 	bb *bytes.Buffer
 }
 
+// NewPage. buat page kosong berukuran blockSize. buffer page aligned (buat direct I/O).
 func NewPage(blockSize int) *Page {
 
-	bb := bytes.NewBuffer(make([]byte, blockSize))
+	bb := bytes.NewBuffer(alignedBuffer(blockSize))
 	return &Page{bb}
 }
 
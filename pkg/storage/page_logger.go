@@ -0,0 +1,39 @@
+package storage
+
+/*
+page logging. selama page logger dipasang (SetPageLogger), isi block dikirim ke page logger sebelum block diwrite ke disk.
+page logger dipanggil sambil hold latch block, jadi read block yang bersamaan (mis. copy block pas online backup) dapat isi block sebelum write
+& isi block setelah write pasti sudah dicatat page logger.
+*/
+
+// PageLogger. dipanggil DiskManager sebelum block diwrite selama page logging aktif, mis. buat log page image selama online backup.
+type PageLogger interface {
+	LogPage(blockID BlockID, contents []byte) error
+}
+
+// SetPageLogger. pasang page logger buat semua write block berikutnya. nil buat berhenti.
+func (dm *DiskManager) SetPageLogger(logger PageLogger) {
+	if logger == nil {
+		dm.pageLogger.Store(nil)
+		return
+	}
+	dm.pageLogger.Store(&logger)
+}
+
+// PageLogger. return page logger yang sedang dipasang, nil kalau tidak ada.
+func (dm *DiskManager) PageLogger() PageLogger {
+	logger := dm.pageLogger.Load()
+	if logger == nil {
+		return nil
+	}
+	return *logger
+}
+
+// logPage. kirim isi block ke page logger (kalau ada). caller harus hold latch block.
+func (dm *DiskManager) logPage(blockID BlockID, contents []byte) error {
+	logger := dm.pageLogger.Load()
+	if logger == nil {
+		return nil
+	}
+	return (*logger).LogPage(blockID, contents)
+}
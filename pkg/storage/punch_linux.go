@@ -0,0 +1,19 @@
+//go:build linux
+
+package storage
+
+import "syscall"
+
+const (
+	fallocFlKeepSize  = 0x1
+	fallocFlPunchHole = 0x2
+)
+
+// PunchHole. dealokasi range [off, off+size) di file tanpa mengubah ukuran file. range yang di punch diread sebagai 0.
+func (f *osFile) PunchHole(off, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocFlPunchHole|fallocFlKeepSize, off, size)
+	if err == syscall.EOPNOTSUPP {
+		return nil // file system tidak support punch hole, slot tetap teralokasi
+	}
+	return err
+}
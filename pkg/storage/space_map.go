@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+/*
+space map. bitmap alokasi block per file, disimpan di file terpisah <filename>.fsm.
+satu page space map (blockSize bytes) mencatat blockSize*8 block. bit = 1 artinya block free (sudah di Free & bisa dipakai lagi oleh Allocate).
+block yang belum tercatat di space map dianggap teralokasi.
+*/
+const spaceMapFileExt = ".fsm"
+
+// ErrBlockAlreadyFree. dikembalikan Free kalau block sudah free (double free).
+var ErrBlockAlreadyFree = errors.New("block is already free")
+
+// spaceMapLocation. return page space map & posisi bit buat blockNum.
+func (dm *DiskManager) spaceMapLocation(blockID BlockID) (BlockID, int, byte) {
+	bitsPerPage := dm.blockSize * 8
+	mapBlock := NewBlockID(blockID.GetFilename()+spaceMapFileExt, blockID.GetBlockNum()/bitsPerPage)
+	bit := blockID.GetBlockNum() % bitsPerPage
+	return mapBlock, bit / 8, byte(1) << (bit % 8)
+}
+
+// readSpaceMapPage. read satu page space map. page yang belum ada di file dianggap kosong (semua block teralokasi).
+func (dm *DiskManager) readSpaceMapPage(mapBlock BlockID) (*Page, error) {
+	page := NewPage(dm.blockSize)
+	err := dm.Read(mapBlock, page)
+	if err == io.EOF {
+		return page, nil
+	}
+	return page, err
+}
+
+/*
+Allocate. alokasi satu block di file fileName. block free (yang sebelumnya di Free) dipakai lagi dulu, kalau tidak ada baru file diperbesar (Append).
+block yang dipakai lagi diisi 0. perubahan space map di fsync sebelum block dikembalikan, biar block tidak dialokasi dua kali setelah crash.
+*/
+func (dm *DiskManager) Allocate(fileName string) (BlockID, error) {
+	df, err := dm.acquireFile(fileName)
+	if err != nil {
+		return BlockID{}, err
+	}
+	defer dm.releaseFile(df)
+	df.allocMu.Lock() // serialize Allocate & Free di file yang sama
+	defer df.allocMu.Unlock()
+
+	mapFile := fileName + spaceMapFileExt
+	numMapPages, err := dm.BlockLength(mapFile)
+	if err != nil {
+		return BlockID{}, err
+	}
+	for i := 0; i < numMapPages; i++ {
+		mapBlock := NewBlockID(mapFile, i)
+		page, err := dm.readSpaceMapPage(mapBlock)
+		if err != nil {
+			return BlockID{}, err
+		}
+		bitmap := page.Contents()
+		for byteIdx, b := range bitmap {
+			for b != 0 && df.reserved[i*dm.blockSize*8+byteIdx*8+bits.TrailingZeros8(b)] {
+				b &^= 1 << bits.TrailingZeros8(b) // block tujuan RelocateBlock yang sedang dicopy
+			}
+			if b == 0 {
+				continue
+			}
+			bit := bits.TrailingZeros8(b)
+			blockID := NewBlockID(fileName, i*dm.blockSize*8+byteIdx*8+bit)
+
+			bitmap[byteIdx] &^= 1 << bit // tandai block teralokasi
+			err = dm.WriteBlocks([]BlockWrite{{BlockID: mapBlock, Page: page}})
+			if err != nil {
+				return BlockID{}, err
+			}
+			err = dm.Write(blockID, NewPage(dm.FileBlockSize(fileName))) // kosongkan isi block yang dipakai lagi
+			if err != nil {
+				return BlockID{}, err
+			}
+			return blockID, nil
+		}
+	}
+
+	return dm.Append(fileName)
+}
+
+// Free. tandai block sebagai free di space map, biar bisa dipakai lagi oleh Allocate.
+func (dm *DiskManager) Free(blockID BlockID) error {
+	df, err := dm.acquireFile(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	df.allocMu.Lock()
+	defer df.allocMu.Unlock()
+
+	numBlocks, err := dm.BlockLength(blockID.GetFilename())
+	if err != nil {
+		return err
+	}
+	if blockID.GetBlockNum() < 0 || blockID.GetBlockNum() >= numBlocks {
+		return fmt.Errorf("cannot free block %d of file %s: block does not exist", blockID.GetBlockNum(), blockID.GetFilename())
+	}
+
+	mapBlock, byteIdx, mask := dm.spaceMapLocation(blockID)
+	page, err := dm.readSpaceMapPage(mapBlock)
+	if err != nil {
+		return err
+	}
+	bitmap := page.Contents()
+	if bitmap[byteIdx]&mask != 0 {
+		return fmt.Errorf("%w: file %s block %d", ErrBlockAlreadyFree, blockID.GetFilename(), blockID.GetBlockNum())
+	}
+	bitmap[byteIdx] |= mask
+	return dm.WriteBlocks([]BlockWrite{{BlockID: mapBlock, Page: page}})
+}
+
+// IsFree. return true kalau block sudah di Free & belum dialokasi lagi.
+func (dm *DiskManager) IsFree(blockID BlockID) (bool, error) {
+	mapBlock, byteIdx, mask := dm.spaceMapLocation(blockID)
+	page, err := dm.readSpaceMapPage(mapBlock)
+	if err != nil {
+		return false, err
+	}
+	return page.Contents()[byteIdx]&mask != 0, nil
+}
+
+// NumFreeBlocks. return jumlah block free di file fileName.
+func (dm *DiskManager) NumFreeBlocks(fileName string) (int, error) {
+	mapFile := fileName + spaceMapFileExt
+	numMapPages, err := dm.BlockLength(mapFile)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := 0; i < numMapPages; i++ {
+		page, err := dm.readSpaceMapPage(NewBlockID(mapFile, i))
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range page.Contents() {
+			count += bits.OnesCount8(b)
+		}
+	}
+	return count, nil
+}
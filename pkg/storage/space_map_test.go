@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaceMap(t *testing.T) {
+	dm, err := NewDiskManager("lintangdb_fsm", 4096, WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+
+	// file kosong: Allocate memperbesar file
+	for i := 0; i < 5; i++ {
+		blockID, err := dm.Allocate("table.db")
+		assert.NoError(t, err)
+		assert.Equal(t, i, blockID.GetBlockNum())
+	}
+
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.Write(NewBlockID("table.db", 3), page))
+
+	assert.NoError(t, dm.Free(NewBlockID("table.db", 3)))
+	assert.NoError(t, dm.Free(NewBlockID("table.db", 1)))
+	assert.ErrorIs(t, dm.Free(NewBlockID("table.db", 3)), ErrBlockAlreadyFree)
+	assert.Error(t, dm.Free(NewBlockID("table.db", 10)))
+
+	free, err := dm.IsFree(NewBlockID("table.db", 3))
+	assert.NoError(t, err)
+	assert.True(t, free)
+	numFree, err := dm.NumFreeBlocks("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numFree)
+
+	// block free dipakai lagi sebelum file diperbesar
+	blockID, err := dm.Allocate("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, blockID.GetBlockNum())
+	blockID, err = dm.Allocate("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, blockID.GetBlockNum())
+
+	// isi block yang dipakai lagi sudah dikosongkan
+	assert.NoError(t, dm.Read(blockID, page))
+	assert.Equal(t, "", page.GetString(0))
+
+	blockID, err = dm.Allocate("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, blockID.GetBlockNum())
+
+	numFree, err = dm.NumFreeBlocks("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numFree)
+	length, err := dm.BlockLength("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, length)
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strings"
+)
+
+/*
+tablespace. nama buat directory tempat file block disimpan (mis. SSD buat file index, disk besar buat file arsip).
+tiap file block ada di satu tablespace, ditentukan pas file dibuat (WithFileTablespace / CreateFileInTablespace) & dicatat di manifest.
+path file blockID di-resolve lewat tablespace file nya. file fork (.crc, .fsm, .cmp, .enc, .key, .lsn) disimpan di tablespace yang sama dengan file block nya.
+tablespace default adalah dbDir (tempat MANIFEST, LOCK & file doublewrite).
+*/
+
+// DefaultTablespace. tablespace dbDir.
+const DefaultTablespace = "default"
+
+var (
+	ErrTablespaceExists   = errors.New("tablespace already exists")
+	ErrTablespaceNotFound = errors.New("tablespace not found")
+	ErrTablespaceNotEmpty = errors.New("tablespace is not empty")
+	ErrTablespaceMismatch = errors.New("file is in a different tablespace")
+)
+
+// forkFileExts. extension file fork. file fork disimpan di tablespace file block nya.
+var forkFileExts = []string{".tmp", checksumFileExt, spaceMapFileExt, compressionFileExt, encryptionFileExt, keyFileExt, blockLSNFileExt}
+
+// WithFileTablespace. file-file ini dibuat di tablespace tablespace. file yang sudah ada tetap di tablespace yang tercatat di manifest.
+func WithFileTablespace(tablespace string, filenames ...string) DiskManagerOption {
+	return func(dm *DiskManager) {
+		for _, filename := range filenames {
+			dm.fileTablespaces[filename] = tablespace
+		}
+	}
+}
+
+// forkBase. return nama file block dari nama file fork (mis. test.db.fsm.crc -> test.db).
+func forkBase(filename string) string {
+	for {
+		trimmed := filename
+		for _, ext := range forkFileExts {
+			trimmed = strings.TrimSuffix(trimmed, ext)
+		}
+		if trimmed == filename {
+			return filename
+		}
+		filename = trimmed
+	}
+}
+
+// dbFilePath. return path file di dbDir (MANIFEST, LOCK).
+func (dm *DiskManager) dbFilePath(filename string) string {
+	return filepath.Join(dm.dbDir, filename)
+}
+
+// filePath. return path file di directory tablespace file tsb.
+func (dm *DiskManager) filePath(filename string) string {
+	m := dm.manifest
+	if m == nil {
+		return dm.dbFilePath(filename)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, ok := m.tablespaces[dm.fileTablespaceLocked(filename)]
+	if !ok {
+		dir = dm.dbDir
+	}
+	return filepath.Join(dir, filename)
+}
+
+// FileTablespace. return tablespace file filename: yang tercatat di manifest, yang dikonfigurasi WithFileTablespace, atau tablespace default.
+func (dm *DiskManager) FileTablespace(filename string) string {
+	m := dm.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return dm.fileTablespaceLocked(filename)
+}
+
+// fileTablespaceLocked. lihat FileTablespace. caller harus hold dm.manifest.mu.
+func (dm *DiskManager) fileTablespaceLocked(filename string) string {
+	base := forkBase(filename)
+	if entry, ok := dm.manifest.files[base]; ok {
+		return entry.tablespace
+	}
+	if tablespace, ok := dm.fileTablespaces[base]; ok {
+		return tablespace
+	}
+	return DefaultTablespace
+}
+
+// CreateTablespace. buat tablespace name di directory dir (dibuat kalau belum ada) & catat di manifest.
+func (dm *DiskManager) CreateTablespace(name, dir string) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot create tablespace %s", ErrReadOnly, name)
+	}
+	if name == "" || len(name) > 0xffff || len(dir) > 0xffff {
+		return fmt.Errorf("invalid tablespace name %q", name)
+	}
+	m := dm.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tablespaces[name]; ok || name == DefaultTablespace {
+		return fmt.Errorf("%w: %s", ErrTablespaceExists, name)
+	}
+	err := dm.fs.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	m.tablespaces[name] = dir
+	err = dm.writeManifest(m)
+	if err != nil {
+		delete(m.tablespaces, name)
+	}
+	return err
+}
+
+// DropTablespace. hapus tablespace name dari manifest. tablespace yang masih punya file tidak bisa dihapus. directory tablespace tidak dihapus.
+func (dm *DiskManager) DropTablespace(name string) error {
+	if dm.readOnly {
+		return fmt.Errorf("%w: cannot drop tablespace %s", ErrReadOnly, name)
+	}
+	if name == DefaultTablespace {
+		return fmt.Errorf("cannot drop tablespace %s", name)
+	}
+	m := dm.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, ok := m.tablespaces[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTablespaceNotFound, name)
+	}
+	for filename, entry := range m.files {
+		if entry.tablespace == name {
+			return fmt.Errorf("%w: %s still has file %s", ErrTablespaceNotEmpty, name, filename)
+		}
+	}
+	delete(m.tablespaces, name)
+	err := dm.writeManifest(m)
+	if err != nil {
+		m.tablespaces[name] = dir
+	}
+	return err
+}
+
+// Tablespaces. return semua tablespace database (nama -> directory), termasuk tablespace default.
+func (dm *DiskManager) Tablespaces() map[string]string {
+	m := dm.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tablespaces := maps.Clone(m.tablespaces)
+	tablespaces[DefaultTablespace] = dm.dbDir
+	return tablespaces
+}
+
+// CreateFileInTablespace. buat file filename di tablespace tablespace dengan block size blockSize & catat di manifest.
+// return error kalau file sudah ada di tablespace lain / dengan block size lain.
+func (dm *DiskManager) CreateFileInTablespace(tablespace, filename string, blockSize int) error {
+	err := validateBlockSize(blockSize)
+	if err != nil {
+		return err
+	}
+	return dm.createFile(filename, fileEntry{blockSize: blockSize, tablespace: tablespace})
+}
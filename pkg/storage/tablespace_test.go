@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTablespace(t *testing.T) {
+	fs := NewMemFS()
+	dm, err := NewDiskManager("lintangdb_ts", 4096, WithVFS(fs), WithFileTablespace("fast", "index.db"))
+	assert.NoError(t, err)
+
+	// tablespace belum dibuat
+	_, err = dm.Append("index.db")
+	assert.ErrorIs(t, err, ErrTablespaceNotFound)
+
+	assert.NoError(t, dm.CreateTablespace("fast", "lintangdb_ts_fast"))
+	assert.NoError(t, dm.CreateTablespace("archive", "lintangdb_ts_archive"))
+	assert.ErrorIs(t, dm.CreateTablespace("fast", "lintangdb_ts_other"), ErrTablespaceExists)
+	assert.ErrorIs(t, dm.CreateTablespace(DefaultTablespace, "lintangdb_ts_other"), ErrTablespaceExists)
+	assert.Equal(t, map[string]string{DefaultTablespace: "lintangdb_ts", "fast": "lintangdb_ts_fast", "archive": "lintangdb_ts_archive"}, dm.Tablespaces())
+
+	blockID, err := dm.Allocate("index.db")
+	assert.NoError(t, err)
+	page := NewPage(4096)
+	page.PutString(0, "lintang")
+	assert.NoError(t, dm.WriteBlocks([]BlockWrite{{BlockID: blockID, Page: page}}))
+	assert.NoError(t, dm.Free(blockID))
+	assert.NoError(t, dm.CreateFileInTablespace("archive", "old.db", 8192))
+	assert.ErrorIs(t, dm.CreateFileInTablespace("fast", "old.db", 8192), ErrTablespaceMismatch)
+	assert.Equal(t, "fast", dm.FileTablespace("index.db"))
+	assert.Equal(t, "fast", dm.FileTablespace("index.db.fsm"))
+	assert.Equal(t, DefaultTablespace, dm.FileTablespace("test.db"))
+
+	// file block & file fork nya disimpan di directory tablespace
+	for _, path := range []string{"lintangdb_ts_fast/index.db", "lintangdb_ts_fast/index.db.crc", "lintangdb_ts_fast/index.db.fsm", "lintangdb_ts_archive/old.db"} {
+		_, err = fs.Stat(path)
+		assert.NoError(t, err, path)
+	}
+	_, err = fs.Stat("lintangdb_ts/index.db")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.ErrorIs(t, dm.DropTablespace("fast"), ErrTablespaceNotEmpty)
+	assert.ErrorIs(t, dm.DropTablespace("missing"), ErrTablespaceNotFound)
+	assert.NoError(t, dm.CreateTablespace("empty", "lintangdb_ts_empty"))
+	assert.NoError(t, dm.DropTablespace("empty"))
+	assert.NoError(t, dm.Close())
+
+	// reopen: tablespace & lokasi file dibaca dari manifest
+	dm, err = NewDiskManager("lintangdb_ts", 0, WithVFS(fs))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{DefaultTablespace: "lintangdb_ts", "fast": "lintangdb_ts_fast", "archive": "lintangdb_ts_archive"}, dm.Tablespaces())
+	page = NewPage(4096)
+	assert.NoError(t, dm.Read(blockID, page))
+	assert.Equal(t, "lintang", page.GetString(0))
+	reused, err := dm.Allocate("index.db")
+	assert.NoError(t, err)
+	assert.Equal(t, blockID, reused)
+	assert.Equal(t, 8192, dm.FileBlockSize("old.db"))
+	assert.Equal(t, "archive", dm.FileTablespace("old.db"))
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"fmt"
+)
+
+/*
+vacuum. setelah banyak block di Free, file data tetap sebesar ukuran puncaknya. RelocateBlock memindahkan block live dari akhir file ke block free di depan file,
+lalu TruncateFree membuang block free di akhir file.
+*/
+
+// loadSpaceMap. read bitmap space map file fileName buat numBlocks block pertama.
+func (dm *DiskManager) loadSpaceMap(fileName string, numBlocks int) ([]byte, error) {
+	bitsPerPage := dm.blockSize * 8
+	bitmap := make([]byte, 0, (numBlocks+7)/8)
+	for i := 0; i*bitsPerPage < numBlocks; i++ {
+		page, err := dm.readSpaceMapPage(NewBlockID(fileName+spaceMapFileExt, i))
+		if err != nil {
+			return nil, err
+		}
+		bitmap = append(bitmap, page.Contents()...)
+	}
+	return bitmap, nil
+}
+
+func isFreeBit(bitmap []byte, blockNum int) bool {
+	return bitmap[blockNum/8]&(byte(1)<<(blockNum%8)) != 0
+}
+
+/*
+RelocateBlock. pindahkan block live terakhir di file fileName ke block free pertama. canMove dipanggil sebelum block from dicopy (mis. buat flush dirty page di buffer pool),
+return false kalau block from tidak boleh dipindah (mis. sedang di pin), block live sebelumnya yang dicoba.
+block from tetap teralokasi: caller harus update referensi ke from jadi to, lalu Free(from). ok = false kalau tidak ada lagi block yang bisa dipindah ke depan.
+canMove & copy jalan tanpa hold allocMu (block to di-reserve, jadi tidak dialokasi Allocate), jadi canMove boleh pakai lock yang juga dipegang caller Allocate/Free.
+*/
+func (dm *DiskManager) RelocateBlock(fileName string, canMove func(from BlockID) (bool, error)) (from, to BlockID, ok bool, err error) {
+	df, err := dm.acquireFile(fileName)
+	if err != nil {
+		return BlockID{}, BlockID{}, false, err
+	}
+	defer dm.releaseFile(df)
+
+	reserved, candidates, err := dm.reserveRelocation(df, fileName)
+	if err != nil || len(candidates) == 0 {
+		return BlockID{}, BlockID{}, false, err
+	}
+	defer func() {
+		df.allocMu.Lock()
+		delete(df.reserved, reserved.GetBlockNum())
+		df.allocMu.Unlock()
+	}()
+
+	for _, from := range candidates {
+		if canMove != nil {
+			ok, err := canMove(from)
+			if err != nil {
+				return BlockID{}, BlockID{}, false, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		ok, err := dm.copyBlock(df, from, reserved)
+		if err != nil {
+			return BlockID{}, BlockID{}, false, err
+		}
+		if ok {
+			return from, reserved, true, nil
+		}
+	}
+	return BlockID{}, BlockID{}, false, nil
+}
+
+// reserveRelocation. reserve block free pertama file fileName sebagai tujuan RelocateBlock, return juga block live setelahnya (dari akhir file).
+func (dm *DiskManager) reserveRelocation(df *diskFile, fileName string) (BlockID, []BlockID, error) {
+	df.allocMu.Lock()
+	defer df.allocMu.Unlock()
+
+	numBlocks, err := dm.BlockLength(fileName)
+	if err != nil {
+		return BlockID{}, nil, err
+	}
+	bitmap, err := dm.loadSpaceMap(fileName, numBlocks)
+	if err != nil {
+		return BlockID{}, nil, err
+	}
+
+	free := 0
+	for free < numBlocks && (!isFreeBit(bitmap, free) || df.reserved[free]) {
+		free++
+	}
+	var candidates []BlockID
+	for live := numBlocks - 1; live > free; live-- {
+		if !isFreeBit(bitmap, live) && !df.reserved[live] {
+			candidates = append(candidates, NewBlockID(fileName, live))
+		}
+	}
+	if len(candidates) == 0 {
+		return BlockID{}, nil, nil
+	}
+	if df.reserved == nil {
+		df.reserved = make(map[int]bool)
+	}
+	df.reserved[free] = true
+	return NewBlockID(fileName, free), candidates, nil
+}
+
+/*
+copyBlock. copy isi block from ke block free to (reserved) & fsync, lalu tandai to teralokasi di space map.
+return false kalau from sudah di Free selama dicopy (to tetap free).
+*/
+func (dm *DiskManager) copyBlock(df *diskFile, from, to BlockID) (bool, error) {
+	page := NewPage(dm.FileBlockSize(from.GetFilename()))
+	err := dm.Read(from, page)
+	if err != nil {
+		return false, err
+	}
+	err = dm.Write(to, page)
+	if err != nil {
+		return false, err
+	}
+	err = dm.SyncFile(to.GetFilename()) // isi block to persist sebelum to ditandai teralokasi
+	if err != nil {
+		return false, err
+	}
+
+	df.allocMu.Lock()
+	defer df.allocMu.Unlock()
+	free, err := dm.IsFree(from)
+	if err != nil || free {
+		return false, err
+	}
+	mapBlock, byteIdx, mask := dm.spaceMapLocation(to)
+	mapPage, err := dm.readSpaceMapPage(mapBlock)
+	if err != nil {
+		return false, err
+	}
+	mapPage.Contents()[byteIdx] &^= mask
+	return true, dm.WriteBlocks([]BlockWrite{{BlockID: mapBlock, Page: mapPage}})
+}
+
+/*
+TruncateFree. buang block free di akhir file fileName (& checksum, panjang compressed, nonce encryption, block LSN nya), return jumlah block yang dibuang.
+bit space map block yang dibuang di clear & di fsync dulu sebelum file di truncate: kalau crash di tengah, block di akhir file cuma dianggap teralokasi, tidak dialokasi dua kali.
+*/
+func (dm *DiskManager) TruncateFree(fileName string) (int, error) {
+	df, err := dm.acquireFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.releaseFile(df)
+	df.allocMu.Lock()
+	defer df.allocMu.Unlock()
+	df.appendMu.Lock() // Append tidak boleh jalan selama file di truncate
+	defer df.appendMu.Unlock()
+
+	numBlocks, err := dm.BlockLength(fileName)
+	if err != nil {
+		return 0, err
+	}
+	bitmap, err := dm.loadSpaceMap(fileName, numBlocks)
+	if err != nil {
+		return 0, err
+	}
+	newLength := numBlocks
+	for newLength > 0 && isFreeBit(bitmap, newLength-1) && !df.reserved[newLength-1] {
+		newLength--
+	}
+	if newLength == numBlocks {
+		return 0, nil
+	}
+
+	var writes []BlockWrite
+	for blockNum := newLength; blockNum < numBlocks; blockNum++ {
+		mapBlock, byteIdx, mask := dm.spaceMapLocation(NewBlockID(fileName, blockNum))
+		if len(writes) == 0 || writes[len(writes)-1].BlockID != mapBlock {
+			page, err := dm.readSpaceMapPage(mapBlock)
+			if err != nil {
+				return 0, err
+			}
+			writes = append(writes, BlockWrite{BlockID: mapBlock, Page: page})
+		}
+		writes[len(writes)-1].Page.Contents()[byteIdx] &^= mask
+	}
+	err = dm.WriteBlocks(writes)
+	if err != nil {
+		return 0, err
+	}
+	err = dm.truncateBlocks(df, newLength)
+	if err != nil {
+		return 0, err
+	}
+	return numBlocks - newLength, nil
+}
+
+// Truncate. buang block file fileName mulai block numBlocks (& checksum, panjang compressed, nonce encryption, block LSN nya), mis. pas restore file
+// yang sudah diperkecil setelah backup base. space map file tidak diubah. file yang tidak lebih panjang dari numBlocks tidak diubah.
+func (dm *DiskManager) Truncate(fileName string, numBlocks int) error {
+	df, err := dm.acquireFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	df.allocMu.Lock()
+	defer df.allocMu.Unlock()
+	df.appendMu.Lock()
+	defer df.appendMu.Unlock()
+
+	length, err := dm.BlockLength(fileName)
+	if err != nil || length <= numBlocks {
+		return err
+	}
+	return dm.truncateBlocks(df, numBlocks)
+}
+
+// truncateBlocks. truncate file df & file pendampingnya jadi newLength block, lalu fsync. caller harus hold df.allocMu & df.appendMu.
+func (dm *DiskManager) truncateBlocks(df *diskFile, newLength int) error {
+	fileName := df.name
+	err := dm.truncateFile(df, int64(newLength*dm.FileBlockSize(fileName)))
+	if err != nil {
+		return err
+	}
+	err = dm.truncateFork(fileName+checksumFileExt, int64(newLength*checksumSize))
+	if err != nil {
+		return err
+	}
+	codec, keys, err := dm.blockTransforms(fileName)
+	if err != nil {
+		return err
+	}
+	if codec != nil {
+		err = dm.truncateFork(fileName+compressionFileExt, int64(compressionHeaderSize+newLength*compressedLengthSize))
+		if err != nil {
+			return err
+		}
+	}
+	if keys != nil {
+		err = dm.truncateFork(fileName+encryptionFileExt, int64(newLength*encryptionEntrySize))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dm.fs.Stat(dm.filePath(fileName + blockLSNFileExt))
+	if err == nil {
+		err = dm.truncateFork(fileName+blockLSNFileExt, int64(newLength*blockLSNSize))
+		if err != nil {
+			return err
+		}
+	}
+	err = dm.SyncFile(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to sync truncated file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// truncateFork. truncate file pendamping (checksum, .cmp, .enc, .lsn) ke size bytes.
+func (dm *DiskManager) truncateFork(forkName string, size int64) error {
+	df, err := dm.acquireFile(forkName)
+	if err != nil {
+		return err
+	}
+	defer dm.releaseFile(df)
+	return dm.truncateFile(df, size)
+}
+
+// truncateFile. truncate file df ke size bytes. mapping mmap file diremap biar tidak ada read di luar file.
+func (dm *DiskManager) truncateFile(df *diskFile, size int64) error {
+	if df.readOnly {
+		return fmt.Errorf("%w: cannot truncate %s", ErrReadOnly, df.name)
+	}
+	m := df.getMmap()
+	if m != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	err := df.f.Truncate(size)
+	if err != nil {
+		return err
+	}
+	df.dirty.Store(true)
+	if m != nil {
+		return m.remap()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVacuum(t *testing.T) {
+	defer os.RemoveAll("lintangdb_vacuum")
+	dm, err := NewDiskManager("lintangdb_vacuum", 4096, WithMmap("table.db"))
+	assert.NoError(t, err)
+	defer dm.Close()
+
+	for i := 0; i < 10; i++ {
+		blockID, err := dm.Allocate("table.db")
+		assert.NoError(t, err)
+		page := NewPage(4096)
+		page.PutString(0, fmt.Sprintf("lintang%d", i))
+		assert.NoError(t, dm.Write(blockID, page))
+	}
+	for _, blockNum := range []int{1, 2, 5, 9} {
+		assert.NoError(t, dm.Free(NewBlockID("table.db", blockNum)))
+	}
+
+	// block 8 tidak boleh dipindah (mis. sedang di pin)
+	canMove := func(from BlockID) (bool, error) {
+		return from.GetBlockNum() != 8, nil
+	}
+	moved := map[int]int{}
+	for {
+		from, to, ok, err := dm.RelocateBlock("table.db", canMove)
+		assert.NoError(t, err)
+		if !ok {
+			break
+		}
+		moved[from.GetBlockNum()] = to.GetBlockNum()
+		assert.NoError(t, dm.Free(from))
+	}
+	assert.Equal(t, map[int]int{7: 1, 6: 2}, moved)
+
+	truncated, err := dm.TruncateFree("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, truncated)
+	length, err := dm.BlockLength("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, length)
+
+	// sisa block free (6, 7) di depan block 8 yang tidak bisa dipindah
+	numFree, err := dm.NumFreeBlocks("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, numFree)
+
+	page := NewPage(4096)
+	for blockNum, want := range map[int]string{0: "lintang0", 1: "lintang7", 2: "lintang6", 3: "lintang3", 8: "lintang8"} {
+		assert.NoError(t, dm.Read(NewBlockID("table.db", blockNum), page))
+		assert.Equal(t, want, page.GetString(0))
+	}
+
+	// block di akhir file yang di truncate tidak dialokasi lagi dari space map
+	blockID, err := dm.Allocate("table.db")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, blockID.GetBlockNum())
+}
+
+func TestRelocateBlockConcurrentAllocate(t *testing.T) {
+	dm, err := NewDiskManager("lintangdb_vacuum_alloc", 4096, WithVFS(NewMemFS()))
+	assert.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := dm.Allocate("table.db")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, dm.Free(NewBlockID("table.db", 0)))
+	assert.NoError(t, dm.Free(NewBlockID("table.db", 1)))
+
+	// canMove jalan tanpa hold allocMu: Allocate di dalamnya tidak deadlock & tidak dapat block tujuan relocate
+	var allocated BlockID
+	from, to, ok, err := dm.RelocateBlock("table.db", func(from BlockID) (bool, error) {
+		var err error
+		allocated, err = dm.Allocate("table.db")
+		return true, err
+	})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, from.GetBlockNum())
+	assert.Equal(t, 0, to.GetBlockNum())
+	assert.Equal(t, 1, allocated.GetBlockNum())
+	assert.NoError(t, dm.Close())
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"io"
+	"os"
+)
+
+// VFS. storage backend (file system) yang dipakai DiskManager buat simpan file database.
+// implementasinya: OSFS (file di OS) & MemFS (in-memory, buat test & database ephemeral).
+type VFS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+}
+
+// File. satu file yang dibuka dari VFS. read/write selalu pakai offset (positional).
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Size() (int64, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// WithVFS. pilih storage backend yang dipakai DiskManager. default OSFS.
+func WithVFS(fs VFS) DiskManagerOption {
+	return func(dm *DiskManager) {
+		dm.fs = fs
+	}
+}
+
+// OSFS. VFS yang simpan file di file system OS.
+type OSFS struct{}
+
+func NewOSFS() *OSFS {
+	return &OSFS{}
+}
+
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{f}, nil
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+type osFile struct {
+	*os.File
+}
+
+// Size. return ukuran file dalam bytes.
+func (f *osFile) Size() (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}